@@ -0,0 +1,731 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"donut/config"
+	"donut/models"
+	"donut/storage"
+)
+
+// ErrUsage is returned when a command is called with invalid arguments.
+// The usage of the command has already been printed.
+var ErrUsage = errors.New("invalid usage")
+
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(c *env, args []string) error
+	// mutates marks the commands that change projects, after which the
+	// trash is purged as configured. Other commands never write anything
+	// they were not asked to
+	mutates bool
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"add", "<project> <title>", "Add a todo, creating the project if needed", runAdd, true},
+		{"ls", "[project] [--json|--ndjson]", "List projects and their todos", runList, false},
+		{"done", "<project> <id>...", "Mark todos as completed", runDone, true},
+		{"rm", "<project> <id>", "Delete a todo and its subtasks", runRemove, true},
+		{"edit", "<project> <id> [title]", "Change the title, dates or priority of a todo", runEdit, true},
+		{"mv", "[project] <id> <target> [--copy]", "Move or copy a todo and its subtasks to another project", runMove, true},
+		{"rename", "<project> <name> [--keep-file]", "Rename a project and its file, updating wiki-links to it", runRename, true},
+		{"import", "<dir>", "Copy the projects of a markdown directory into the backend", runImport, false},
+		{"export", "<dir>", "Copy the projects of the backend into a markdown directory", runExport, false},
+		{"trash", "[restore|purge <id>]", "List, restore or purge deleted projects", runTrash, false},
+	}
+}
+
+// env holds what every command needs to read and write todos.
+type env struct {
+	config  *config.Config
+	storage storage.Backend
+	data    *models.AppData
+	out     io.Writer
+}
+
+// Run executes the subcommand named by args[0] with the remaining
+// arguments.
+func Run(args []string) error {
+	if len(args) == 0 {
+		return ErrUsage
+	}
+
+	cmd, ok := findCommand(args[0])
+	if !ok {
+		return fmt.Errorf("unknown command %q, run 'donut --help' for usage", args[0])
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	s, err := storage.New(cfg)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	data, err := s.Load()
+	if err != nil {
+		return err
+	}
+	for _, warning := range data.Warnings {
+		fmt.Fprintf(os.Stderr, "donut: warning: %s\n", warning)
+	}
+	// Todos written without an ID get one, saved along with the next
+	// change to their project
+	for i := range data.Projects {
+		data.Projects[i].EnsureIDs()
+	}
+
+	c := &env{
+		config:  cfg,
+		storage: s,
+		data:    data,
+		out:     os.Stdout,
+	}
+	if err := cmd.run(c, args[1:]); err != nil || !cmd.mutates {
+		return err
+	}
+
+	// Completed todos are only archived by the TUI, as archiving rewrites
+	// every project with old enough todos rather than the one changed
+	if _, err := storage.PurgeExpired(s, cfg.TrashRetentionDays, time.Now()); err != nil {
+		fmt.Fprintf(os.Stderr, "donut: warning: purging the trash: %v\n", err)
+	}
+	return nil
+}
+
+// Usage returns the usage lines of every subcommand, for the help text.
+func Usage() string {
+	var lines []string
+	for _, cmd := range commands {
+		usage := fmt.Sprintf("%s %s", cmd.name, cmd.args)
+		lines = append(lines, fmt.Sprintf("    %-28s %s", usage, cmd.summary))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// newFlagSet returns a flag set printing the usage of the command on
+// errors.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		cmd, _ := findCommand(name)
+		fmt.Fprintf(fs.Output(), "Usage: donut %s %s\n", cmd.name, cmd.args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseArgs parses the flags of fs wherever they appear among args and
+// returns the positional arguments.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, ErrUsage
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// todoFlags are the flags shared by the commands setting todo fields.
+type todoFlags struct {
+	due       *string
+	scheduled *string
+	priority  *string
+	notes     *string
+}
+
+func addTodoFlags(fs *flag.FlagSet) todoFlags {
+	return todoFlags{
+		due:       fs.String("due", "", "Due date: YYYY-MM-DD, today, tomorrow or +Nd/+Nw/+Nm, none to clear"),
+		scheduled: fs.String("scheduled", "", "Scheduled date, in the same format as --due"),
+		priority:  fs.String("priority", "", "Priority: highest, high, medium, none, low or lowest"),
+		notes:     fs.String("notes", "", "Notes, possibly spanning several lines, none to clear"),
+	}
+}
+
+// apply sets the fields given on the command line on todo.
+func (f todoFlags) apply(todo *models.Todo) error {
+	now := time.Now()
+	for _, field := range []struct {
+		value  string
+		target *time.Time
+	}{
+		{*f.due, &todo.Due},
+		{*f.scheduled, &todo.Scheduled},
+	} {
+		switch field.value {
+		case "":
+		case "none":
+			*field.target = time.Time{}
+		default:
+			date, err := models.ParseDate(field.value, now)
+			if err != nil {
+				return err
+			}
+			*field.target = date
+		}
+	}
+
+	if *f.priority != "" {
+		priority, err := models.ParsePriority(*f.priority)
+		if err != nil {
+			return err
+		}
+		todo.Priority = priority
+	}
+
+	switch *f.notes {
+	case "":
+	case "none":
+		todo.SetNotes("")
+	default:
+		todo.SetNotes(*f.notes)
+	}
+
+	return nil
+}
+
+// findProject returns the project whose name or filename matches name,
+// ignoring case.
+func (c *env) findProject(name string) (*models.Project, error) {
+	for i := range c.data.Projects {
+		project := &c.data.Projects[i]
+		if strings.EqualFold(project.Name, name) || strings.EqualFold(strings.TrimSuffix(project.Filename, ".md"), name) {
+			return project, nil
+		}
+	}
+	return nil, fmt.Errorf("project %q not found", name)
+}
+
+// findTodo returns the slice holding the todo identified by id and its
+// index in that slice. Ids are either the 1-based positions of the todo and
+// its parents in file order, joined by dots, e.g. "2.1", or the ID of the
+// todo shown after a ^ by `donut ls`, with or without the ^.
+func findTodo(project *models.Project, id string) (*[]models.Todo, int, error) {
+	todos := &project.Todos
+	parts := strings.Split(id, ".")
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 1 || n > len(*todos) {
+			if siblings, index := project.FindByID(strings.TrimPrefix(id, "^")); siblings != nil {
+				return siblings, index, nil
+			}
+			return nil, 0, fmt.Errorf("todo %q not found in %s", id, project.Name)
+		}
+		if i == len(parts)-1 {
+			return todos, n - 1, nil
+		}
+		todos = &(*todos)[n-1].Children
+	}
+	return nil, 0, fmt.Errorf("todo %q not found in %s", id, project.Name)
+}
+
+// todoPosition returns the position id of the todo with the given ID, e.g.
+// "2.1", or an empty string when there is no such todo.
+func todoPosition(todos []models.Todo, id string) string {
+	for i := range todos {
+		position := strconv.Itoa(i + 1)
+		if todos[i].ID == id {
+			return position
+		}
+		if child := todoPosition(todos[i].Children, id); child != "" {
+			return position + "." + child
+		}
+	}
+	return ""
+}
+
+// save writes the project after a change, leaving the other projects
+// untouched.
+func (c *env) save(project *models.Project) error {
+	project.Dirty = true
+	return c.storage.Save(c.data)
+}
+
+func runAdd(c *env, args []string) error {
+	fs := newFlagSet("add")
+	flags := addTodoFlags(fs)
+	parent := fs.String("parent", "", "Id of the todo to add a subtask to")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 {
+		fs.Usage()
+		return ErrUsage
+	}
+
+	name := args[0]
+	title := strings.TrimSpace(strings.Join(args[1:], " "))
+	if title == "" {
+		return errors.New("todo title cannot be empty")
+	}
+
+	project, err := c.findProject(name)
+	if err != nil {
+		filename := storage.ProjectFilename(c.storage, c.config, c.data, name, nil)
+		c.data.Projects = append(c.data.Projects, models.NewProject(name, filename))
+		project = &c.data.Projects[len(c.data.Projects)-1]
+	}
+
+	todo := models.NewTodo(title)
+	if err := flags.apply(&todo); err != nil {
+		return err
+	}
+
+	todos := &project.Todos
+	if *parent != "" {
+		siblings, index, err := findTodo(project, *parent)
+		if err != nil {
+			return err
+		}
+		todos = &(*siblings)[index].Children
+	}
+	*todos = append(*todos, todo)
+	id := todoPosition(project.Todos, todo.ID)
+
+	if c.config.AutoCompleteParents {
+		project.SyncCompletion()
+	}
+	if err := c.save(project); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, "Added %s (^%s) to %s\n", id, todo.ID, project.Name)
+	return nil
+}
+
+func runList(c *env, args []string) error {
+	fs := newFlagSet("ls")
+	open := fs.Bool("open", false, "Only list todos that are not completed")
+	asJSON := fs.Bool("json", false, "Print a single JSON document")
+	asNDJSON := fs.Bool("ndjson", false, "Print one JSON object per todo and line")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) > 1 {
+		fs.Usage()
+		return ErrUsage
+	}
+
+	projects := c.data.Projects
+	if len(args) == 1 {
+		project, err := c.findProject(args[0])
+		if err != nil {
+			return err
+		}
+		projects = []models.Project{*project}
+	}
+
+	switch {
+	case *asJSON:
+		return writeJSON(c.out, projects, *open)
+	case *asNDJSON:
+		return writeNDJSON(c.out, projects, *open)
+	}
+
+	for i, project := range projects {
+		if i > 0 {
+			fmt.Fprintln(c.out)
+		}
+		completed, total := models.CountTodos(project.Todos)
+		fmt.Fprintf(c.out, "%s (%d/%d)\n", project.Name, completed, total)
+		printTodos(c.out, project.Todos, "", *open)
+	}
+	return nil
+}
+
+func printTodos(out io.Writer, todos []models.Todo, prefix string, open bool) {
+	for i := range todos {
+		todo := &todos[i]
+		if open && todo.Completed {
+			continue
+		}
+
+		checkbox := " "
+		if todo.Completed {
+			checkbox = "x"
+		}
+
+		id := prefix + strconv.Itoa(i+1)
+		depth := strings.Count(id, ".")
+		fmt.Fprintf(out, "  %s%-4s [%s] %s\n", strings.Repeat("  ", depth), id, checkbox, storage.FormatTodoText(todo))
+		printTodos(out, todo.Children, id+".", open)
+	}
+}
+
+func runDone(c *env, args []string) error {
+	fs := newFlagSet("done")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 {
+		fs.Usage()
+		return ErrUsage
+	}
+
+	project, err := c.findProject(args[0])
+	if err != nil {
+		return err
+	}
+
+	for _, id := range args[1:] {
+		todos, index, err := findTodo(project, id)
+		if err != nil {
+			return err
+		}
+
+		todo := &(*todos)[index]
+		if c.config.AutoCompleteParents {
+			todo.SetCompleted(true)
+		} else {
+			todo.MarkCompleted(true)
+		}
+		fmt.Fprintf(c.out, "Completed %s: %s\n", id, todo.Title)
+	}
+
+	if c.config.AutoCompleteParents {
+		project.SyncCompletion()
+	}
+	return c.save(project)
+}
+
+func runRemove(c *env, args []string) error {
+	fs := newFlagSet("rm")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 2 {
+		fs.Usage()
+		return ErrUsage
+	}
+
+	project, err := c.findProject(args[0])
+	if err != nil {
+		return err
+	}
+
+	todos, index, err := findTodo(project, args[1])
+	if err != nil {
+		return err
+	}
+
+	title := (*todos)[index].Title
+	*todos = append((*todos)[:index], (*todos)[index+1:]...)
+	if c.config.AutoCompleteParents {
+		project.SyncCompletion()
+	}
+	if err := c.save(project); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, "Deleted %s: %s\n", args[1], title)
+	return nil
+}
+
+func runEdit(c *env, args []string) error {
+	fs := newFlagSet("edit")
+	flags := addTodoFlags(fs)
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 {
+		fs.Usage()
+		return ErrUsage
+	}
+
+	project, err := c.findProject(args[0])
+	if err != nil {
+		return err
+	}
+
+	todos, index, err := findTodo(project, args[1])
+	if err != nil {
+		return err
+	}
+
+	todo := &(*todos)[index]
+	if title := strings.TrimSpace(strings.Join(args[2:], " ")); title != "" {
+		todo.SetTitle(title)
+	}
+	if err := flags.apply(todo); err != nil {
+		return err
+	}
+	if err := c.save(project); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, "Updated %s: %s\n", args[1], todo.Title)
+	return nil
+}
+
+// runMove moves a todo to another project. The project of the todo may be
+// left out when the todo is given by its ID.
+func runMove(c *env, args []string) error {
+	fs := newFlagSet("mv")
+	copyTodo := fs.Bool("copy", false, "Copy the todo, giving the copies new IDs, instead of moving it")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 2 && len(args) != 3 {
+		fs.Usage()
+		return ErrUsage
+	}
+
+	var project *models.Project
+	var todos *[]models.Todo
+	var index int
+	id := args[0]
+	if len(args) == 3 {
+		if project, err = c.findProject(args[0]); err != nil {
+			return err
+		}
+		id = args[1]
+		if todos, index, err = findTodo(project, id); err != nil {
+			return err
+		}
+	} else {
+		for i := range c.data.Projects {
+			if todos, index = c.data.Projects[i].FindByID(strings.TrimPrefix(id, "^")); todos != nil {
+				project = &c.data.Projects[i]
+				break
+			}
+		}
+		if project == nil {
+			return fmt.Errorf("todo %q not found, give its project to find it by position", id)
+		}
+	}
+
+	target, err := c.findProject(args[len(args)-1])
+	if err != nil {
+		return err
+	}
+	if target == project {
+		return fmt.Errorf("%s is already in %s", id, project.Name)
+	}
+
+	title := (*todos)[index].Title
+	if *copyTodo {
+		target.CopyTodo((*todos)[index])
+		target.Dirty = true
+		if err := c.storage.SaveProject(target); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.out, "Copied %s to %s as %d: %s\n", id, target.Name, len(target.Todos), title)
+		return nil
+	}
+
+	target.MoveTodo(todos, index)
+	if c.config.AutoCompleteParents {
+		project.SyncCompletion()
+	}
+	project.Dirty, target.Dirty = true, true
+	// The target is written first, so the todo is never lost
+	if err := c.storage.SaveProjects(target, project); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, "Moved %s to %s as %d: %s\n", id, target.Name, len(target.Todos), title)
+	return nil
+}
+
+func runRename(c *env, args []string) error {
+	fs := newFlagSet("rename")
+	keepFile := fs.Bool("keep-file", false, "Only change the title, keeping the filename")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 {
+		fs.Usage()
+		return ErrUsage
+	}
+
+	project, err := c.findProject(args[0])
+	if err != nil {
+		return err
+	}
+	name := strings.TrimSpace(strings.Join(args[1:], " "))
+	if name == "" {
+		fs.Usage()
+		return ErrUsage
+	}
+
+	filename := storage.ProjectFilename(c.storage, c.config, c.data, name, project)
+	if !*keepFile && filename != project.Filename {
+		changed, err := c.storage.RenameProject(project, filename)
+		if err != nil {
+			return err
+		}
+		// Links of the project to itself were updated in storage only
+		if slices.Contains(changed, project.Filename) {
+			reloaded, err := c.storage.LoadProject(project.Filename)
+			if err != nil {
+				return err
+			}
+			reloaded.EnsureIDs()
+			*project = reloaded
+		}
+	}
+
+	project.Name = name
+	if err := c.save(project); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, "Renamed %s to %s (%s)\n", args[0], name, project.Filename)
+	return nil
+}
+
+func runImport(c *env, args []string) error {
+	fs := newFlagSet("import")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		fs.Usage()
+		return ErrUsage
+	}
+
+	src, err := storage.NewMarkdown(args[0])
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	count, err := storage.Copy(c.storage, src)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, "Imported %d project(s) from %s\n", count, args[0])
+	return nil
+}
+
+func runExport(c *env, args []string) error {
+	fs := newFlagSet("export")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		fs.Usage()
+		return ErrUsage
+	}
+
+	dst, err := storage.NewMarkdown(args[0])
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	// Load the existing files so their other content is kept
+	if _, err := dst.Load(); err != nil {
+		return err
+	}
+
+	count, err := storage.Copy(dst, c.storage)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.out, "Exported %d project(s) to %s\n", count, args[0])
+	return nil
+}
+
+func runTrash(c *env, args []string) error {
+	fs := newFlagSet("trash")
+	all := fs.Bool("all", false, "Purge every project in the trash")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	trash, ok := c.storage.(storage.Trash)
+	if !ok {
+		return fmt.Errorf("the %s backend has no trash, deleted projects are gone", c.config.Backend)
+	}
+
+	if len(args) == 0 && !*all {
+		entries, err := trash.TrashEntries()
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			fmt.Fprintln(c.out, "The trash is empty")
+		}
+		for _, entry := range entries {
+			fmt.Fprintf(c.out, "%s  %s, deleted %s\n", entry.ID, entry.Name, entry.DeletedAt.Format("2006-01-02 15:04"))
+		}
+		return nil
+	}
+
+	switch {
+	case len(args) == 2 && args[0] == "restore" && !*all:
+		project, err := trash.RestoreTrashed(args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(c.out, "Restored %s\n", project.Name)
+		return nil
+
+	case len(args) > 0 && args[0] == "purge" && (len(args) > 1) != *all:
+		ids := args[1:]
+		if *all {
+			entries, err := trash.TrashEntries()
+			if err != nil {
+				return err
+			}
+			for _, entry := range entries {
+				ids = append(ids, entry.ID)
+			}
+		}
+		for i, id := range ids {
+			if err := trash.PurgeTrashed(id); err != nil {
+				return fmt.Errorf("purged %d project(s): %w", i, err)
+			}
+		}
+		fmt.Fprintf(c.out, "Purged %d project(s)\n", len(ids))
+		return nil
+	}
+
+	fs.Usage()
+	return ErrUsage
+}
@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	"donut/config"
+	"donut/models"
+	"donut/storage"
+)
+
+// run runs a command against backend the way Run does, and returns what
+// it printed.
+func run(t *testing.T, backend storage.Backend, args ...string) string {
+	t.Helper()
+
+	cmd, ok := findCommand(args[0])
+	if !ok {
+		t.Fatalf("unknown command %q", args[0])
+	}
+	data, err := backend.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range data.Projects {
+		data.Projects[i].EnsureIDs()
+	}
+
+	var out strings.Builder
+	c := &env{
+		config:  &config.Config{AutoCompleteParents: true},
+		storage: backend,
+		data:    data,
+		out:     &out,
+	}
+	if err := cmd.run(c, args[1:]); err != nil {
+		t.Fatalf("%s: %v", strings.Join(args, " "), err)
+	}
+	return out.String()
+}
+
+func loadProject(t *testing.T, backend storage.Backend, filename string) models.Project {
+	t.Helper()
+	data, err := backend.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, project := range data.Projects {
+		if project.Filename == filename {
+			return project
+		}
+	}
+	t.Fatalf("no project %s", filename)
+	return models.Project{}
+}
+
+func TestAddAndComplete(t *testing.T) {
+	backend := storage.NewMemory()
+	run(t, backend, "add", "Work", "Review pull requests #backend", "--priority", "high")
+	run(t, backend, "add", "Work", "Check the tests", "--parent", "1")
+
+	project := loadProject(t, backend, "work.md")
+	if len(project.Todos) != 1 || len(project.Todos[0].Children) != 1 {
+		t.Fatalf("todos = %+v, want one todo with a subtask", project.Todos)
+	}
+	todo := project.Todos[0]
+	if todo.Priority != models.PriorityHigh || !todo.HasTag("#backend") {
+		t.Errorf("todo = %+v, want a high priority todo tagged backend", todo)
+	}
+
+	// Completing the only subtask completes its parent
+	run(t, backend, "done", "work", todo.Children[0].ID)
+	project = loadProject(t, backend, "work.md")
+	if !project.Todos[0].Completed || !project.Todos[0].Children[0].Completed {
+		t.Errorf("todos = %+v, want the subtask and its parent completed", project.Todos)
+	}
+}
+
+func TestEditNotes(t *testing.T) {
+	backend := storage.NewMemory()
+	run(t, backend, "add", "Work", "Release")
+	run(t, backend, "edit", "work", "1", "--notes", "  Tag the commit\n\n  Publish  ")
+
+	if notes := loadProject(t, backend, "work.md").Todos[0].Notes; notes != "Tag the commit\n\nPublish" {
+		t.Errorf("notes = %q", notes)
+	}
+
+	run(t, backend, "edit", "work", "1", "--notes", "none")
+	if notes := loadProject(t, backend, "work.md").Todos[0].Notes; notes != "" {
+		t.Errorf("notes = %q, want none", notes)
+	}
+}
+
+func TestMove(t *testing.T) {
+	backend := storage.NewMemory()
+	run(t, backend, "add", "Work", "Call Bob")
+	run(t, backend, "add", "Home", "Water the plants")
+	id := loadProject(t, backend, "work.md").Todos[0].ID
+
+	run(t, backend, "mv", id, "home")
+	if todos := loadProject(t, backend, "work.md").Todos; len(todos) != 0 {
+		t.Errorf("work todos = %+v, want none", todos)
+	}
+	home := loadProject(t, backend, "home.md")
+	if len(home.Todos) != 2 || home.Todos[1].ID != id {
+		t.Errorf("home todos = %+v, want the moved todo last with its ID", home.Todos)
+	}
+
+	run(t, backend, "mv", "home", "2", "work", "--copy")
+	work := loadProject(t, backend, "work.md")
+	if len(work.Todos) != 1 || work.Todos[0].ID == id {
+		t.Errorf("work todos = %+v, want a copy with a new ID", work.Todos)
+	}
+}
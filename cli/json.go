@@ -0,0 +1,163 @@
+package cli
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	"donut/models"
+)
+
+// jsonSchemaVersion is bumped whenever a field of the JSON output is
+// removed or changes meaning. Adding fields does not bump it.
+const jsonSchemaVersion = 1
+
+type jsonData struct {
+	Version  int           `json:"version"`
+	Projects []jsonProject `json:"projects"`
+}
+
+type jsonProject struct {
+	Name      string     `json:"name"`
+	Filename  string     `json:"filename"`
+	Completed int        `json:"completed"`
+	Total     int        `json:"total"`
+	Todos     []jsonTodo `json:"todos"`
+}
+
+// jsonFields are the fields of a todo shared by both output formats.
+type jsonFields struct {
+	ID          string   `json:"id"`
+	StableID    string   `json:"stable_id"`
+	Title       string   `json:"title"`
+	Notes       string   `json:"notes"`
+	Completed   bool     `json:"completed"`
+	Priority    string   `json:"priority"`
+	Tags        []string `json:"tags"`
+	Contexts    []string `json:"contexts"`
+	CreatedAt   *string  `json:"created_at"`
+	CompletedAt *string  `json:"completed_at"`
+	Due         *string  `json:"due"`
+	Scheduled   *string  `json:"scheduled"`
+	Line        int      `json:"line"`
+}
+
+type jsonTodo struct {
+	jsonFields
+	Children []jsonTodo `json:"children"`
+}
+
+// jsonRecord is a single todo of the NDJSON output, flattened out of its
+// project and parent.
+type jsonRecord struct {
+	Project  string `json:"project"`
+	Filename string `json:"filename"`
+	Parent   string `json:"parent"`
+	jsonFields
+}
+
+func newJSONProject(project *models.Project, open bool) jsonProject {
+	completed, total := models.CountTodos(project.Todos)
+	return jsonProject{
+		Name:      project.Name,
+		Filename:  project.Filename,
+		Completed: completed,
+		Total:     total,
+		Todos:     newJSONTodos(project.Todos, "", open),
+	}
+}
+
+func newJSONTodos(todos []models.Todo, prefix string, open bool) []jsonTodo {
+	result := []jsonTodo{}
+	for i := range todos {
+		todo := &todos[i]
+		if open && todo.Completed {
+			continue
+		}
+
+		id := prefix + strconv.Itoa(i+1)
+		result = append(result, jsonTodo{
+			jsonFields: jsonFields{
+				ID:          id,
+				StableID:    todo.ID,
+				Title:       todo.Title,
+				Notes:       todo.Notes,
+				Completed:   todo.Completed,
+				Priority:    todo.Priority.String(),
+				Tags:        nonNil(todo.Tags),
+				Contexts:    nonNil(todo.Contexts),
+				CreatedAt:   jsonDate(todo.CreatedAt),
+				CompletedAt: jsonDate(todo.CompletedAt),
+				Due:         jsonDate(todo.Due),
+				Scheduled:   jsonDate(todo.Scheduled),
+				Line:        todo.LineNum,
+			},
+			Children: newJSONTodos(todo.Children, id+".", open),
+		})
+	}
+	return result
+}
+
+// writeJSON writes projects as a single indented JSON document.
+func writeJSON(out io.Writer, projects []models.Project, open bool) error {
+	data := jsonData{
+		Version:  jsonSchemaVersion,
+		Projects: []jsonProject{},
+	}
+	for i := range projects {
+		data.Projects = append(data.Projects, newJSONProject(&projects[i], open))
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(data)
+}
+
+// writeNDJSON writes one JSON object per line for every todo of projects,
+// parents before their subtasks.
+func writeNDJSON(out io.Writer, projects []models.Project, open bool) error {
+	encoder := json.NewEncoder(out)
+
+	var write func(project *models.Project, todos []jsonTodo, parent string) error
+	write = func(project *models.Project, todos []jsonTodo, parent string) error {
+		for _, todo := range todos {
+			record := jsonRecord{
+				Project:    project.Name,
+				Filename:   project.Filename,
+				Parent:     parent,
+				jsonFields: todo.jsonFields,
+			}
+			if err := encoder.Encode(record); err != nil {
+				return err
+			}
+			if err := write(project, todo.Children, todo.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for i := range projects {
+		project := &projects[i]
+		if err := write(project, newJSONTodos(project.Todos, "", open), ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func jsonDate(t time.Time) *string {
+	if t.IsZero() {
+		return nil
+	}
+	date := t.Format("2006-01-02")
+	return &date
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
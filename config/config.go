@@ -10,6 +10,30 @@ import (
 
 type Config struct {
 	DonutDir string `yaml:"donut_dir"`
+	// Backend selects where projects are stored: "markdown" files in
+	// DonutDir, an "sqlite" database at SQLitePath, or "memory" to keep
+	// them in memory only
+	Backend    string `yaml:"backend"`
+	SQLitePath string `yaml:"sqlite_path"`
+	// AutoCompleteParents completes a todo once all of its subtasks are
+	// completed, and completes every subtask when the parent is toggled
+	AutoCompleteParents bool `yaml:"auto_complete_parents"`
+	// SortBy is the order todos are shown in, unless another one was
+	// picked for the project in the TUI: "manual" file order, "created"
+	// date, nearest "due" date, "priority" or "alphabetical"
+	SortBy string `yaml:"sort_by"`
+	// TrashRetentionDays is how long deleted projects stay in the trash,
+	// 0 to keep them until purged by hand
+	TrashRetentionDays int `yaml:"trash_retention_days"`
+	// ArchiveTo keeps archived todos in an "## Archive" "section" of the
+	// project file or in a separate <project>.archive.md "file"
+	ArchiveTo string `yaml:"archive_to"`
+	// ArchiveAfterDays archives todos completed more than that many days
+	// ago when the TUI starts, 0 to only archive by hand
+	ArchiveAfterDays int `yaml:"archive_after_days"`
+	// UnicodeFilenames keeps letters such as "é" or "日" in the filenames
+	// of new projects instead of transliterating them to ASCII
+	UnicodeFilenames bool `yaml:"unicode_filenames"`
 }
 
 func Load() (*Config, error) {
@@ -22,7 +46,12 @@ func Load() (*Config, error) {
 	defaultDonutDir := filepath.Join(homeDir, ".donut")
 
 	config := &Config{
-		DonutDir: defaultDonutDir,
+		DonutDir:            defaultDonutDir,
+		Backend:             "markdown",
+		AutoCompleteParents: true,
+		SortBy:              "created",
+		TrashRetentionDays:  30,
+		ArchiveTo:           "section",
 	}
 
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
@@ -49,6 +78,14 @@ func Load() (*Config, error) {
 		config.DonutDir = filepath.Join(homeDir, config.DonutDir)
 	}
 
+	if config.SQLitePath == "" {
+		config.SQLitePath = filepath.Join(config.DonutDir, "donut.db")
+	} else if strings.HasPrefix(config.SQLitePath, "~/") {
+		config.SQLitePath = filepath.Join(homeDir, config.SQLitePath[2:])
+	} else if !filepath.IsAbs(config.SQLitePath) {
+		config.SQLitePath = filepath.Join(homeDir, config.SQLitePath)
+	}
+
 	return config, nil
 }
 
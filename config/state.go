@@ -0,0 +1,65 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// State holds what the TUI remembers between sessions. It is kept apart
+// from the config file, which is only ever written by the user.
+type State struct {
+	// SortModes maps project filenames to the order their todos are
+	// shown in, when picked in the TUI
+	SortModes map[string]string `yaml:"sort_modes"`
+}
+
+func statePath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".donut-state.yml"), nil
+}
+
+// LoadState reads the state saved by the last session, returning an empty
+// state when there is none.
+func LoadState() (*State, error) {
+	state := &State{SortModes: make(map[string]string)}
+
+	path, err := statePath()
+	if err != nil {
+		return state, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+
+	if err := yaml.Unmarshal(data, state); err != nil {
+		return state, err
+	}
+	if state.SortModes == nil {
+		state.SortModes = make(map[string]string)
+	}
+	return state, nil
+}
+
+func (s *State) Save() error {
+	path, err := statePath()
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
@@ -1,10 +1,13 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"os"
 
+	"donut/cli"
 	"donut/ui"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -27,6 +30,16 @@ func main() {
 		return
 	}
 
+	if flag.NArg() > 0 {
+		if err := cli.Run(flag.Args()); err != nil {
+			if !errors.Is(err, cli.ErrUsage) {
+				fmt.Fprintf(os.Stderr, "donut: %v\n", err)
+			}
+			os.Exit(1)
+		}
+		return
+	}
+
 	model, err := ui.NewModel()
 	if err != nil {
 		log.Fatal(err)
@@ -44,27 +57,62 @@ func showHelpText() {
 
 USAGE:
     donut [OPTIONS]
+    donut <COMMAND> [ARGS]
 
 OPTIONS:
     --version    Show version information
     --help       Show this help message
 
+COMMANDS:
+` + cli.Usage() + `
+
+    Todos are referenced by the id shown by 'donut ls', e.g. 2 or 2.1 for
+    the first subtask of the second todo, or by the ID shown after a ^,
+    which stays the same when todos are edited or moved. add and edit
+    accept --due, --scheduled, --priority and --notes, and add accepts
+    --parent <id>.
+
 KEYBOARD CONTROLS:
 
 Project View:
     ↑/↓, j/k     Navigate projects
     Enter        Select project
     n            Create new project
+    r            Rename project
+    K/J          Move project up/down
     d            Delete project
+    t            Browse the trash
+    f            Filter by tag or context
+    F            Clear filter
+    u            Undo
+    Ctrl+R       Redo
     ?            Show/hide help
     q, Ctrl+C    Quit
 
 Todo View:
     ↑/↓, j/k     Navigate todos
     Space        Toggle todo completion
+    Tab          Expand/collapse subtasks
     n            Create new todo
+    a            Add subtask
     e            Edit todo
+    i            Show/hide the notes of the todo
+    N            Edit notes
+    E            Edit notes in $EDITOR
+    +/-          Raise/lower priority
+    D            Set due date
+    S            Set scheduled date
+    o            Change sort order
+    K/J          Move todo up/down
+    m            Move todo to another project
+    c            Copy todo to another project
+    f            Filter by tag or context
+    F            Clear filter
     d            Delete todo
+    A            Archive completed todos
+    v            Browse the archive
+    u            Undo
+    Ctrl+R       Redo
     Backspace    Return to projects
     ?            Show/hide help
     q, Ctrl+C    Quit
@@ -73,7 +121,18 @@ Input Mode:
     Type         Enter text
     Enter        Confirm
     Esc          Cancel
+    ←/→          Move the cursor, by word with Alt or Ctrl
+    Home/End     Go to the start/end
     Backspace    Delete character
+    Ctrl+W       Delete word
+    Ctrl+U/K     Delete to the start/end
+    ↑/↓          Recall previous entries
+
+Notes Editor:
+    Enter        New line
+    ↑/↓/←/→      Move the cursor
+    Ctrl+S       Save
+    Esc          Cancel
 
 TMUX INTEGRATION:
     Install the tmux plugin by adding to ~/.tmux.conf:
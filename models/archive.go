@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// ArchiveCompleted moves the completed top-level todos, along with their
+// subtasks, to the end of the archive. When cutoff is not zero only the
+// todos completed before cutoff are moved. It returns the number of todos
+// archived
+func (p *Project) ArchiveCompleted(cutoff time.Time) int {
+	var kept []Todo
+	archived := 0
+	for _, todo := range p.Todos {
+		if !todo.Completed || (!cutoff.IsZero() && (todo.CompletedAt.IsZero() || !todo.CompletedAt.Before(cutoff))) {
+			kept = append(kept, todo)
+			continue
+		}
+		// Line numbers point into the list the todo is leaving
+		forgetLines(&todo)
+		p.Archive = append(p.Archive, todo)
+		archived++
+	}
+
+	if archived > 0 {
+		p.Todos = append([]Todo{}, kept...)
+	}
+	return archived
+}
+
+// Unarchive moves the archived todo at index back to the todos
+func (p *Project) Unarchive(index int) {
+	if index < 0 || index >= len(p.Archive) {
+		return
+	}
+	todo := p.Archive[index]
+	forgetLines(&todo)
+	p.Archive = append(p.Archive[:index], p.Archive[index+1:]...)
+	p.Todos = append(p.Todos, todo)
+}
+
+func forgetLines(todo *Todo) {
+	todo.LineNum = -1
+	for i := range todo.Children {
+		forgetLines(&todo.Children[i])
+	}
+}
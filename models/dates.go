@@ -0,0 +1,60 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseDate parses a date typed by the user: an ISO date (2026-10-17),
+// "today", "tomorrow" or an offset from today such as "+3d" or "+2w"
+func ParseDate(input string, now time.Time) (time.Time, error) {
+	input = strings.ToLower(strings.TrimSpace(input))
+	today := startOfDay(now)
+
+	switch input {
+	case "today":
+		return today, nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), nil
+	}
+
+	if strings.HasPrefix(input, "+") && len(input) > 2 {
+		count, err := strconv.Atoi(input[1 : len(input)-1])
+		if err == nil {
+			switch input[len(input)-1] {
+			case 'd':
+				return today.AddDate(0, 0, count), nil
+			case 'w':
+				return today.AddDate(0, 0, 7*count), nil
+			case 'm':
+				return today.AddDate(0, count, 0), nil
+			}
+		}
+	}
+
+	date, err := time.ParseInLocation("2006-01-02", input, now.Location())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, use YYYY-MM-DD, today, tomorrow or +Nd/+Nw/+Nm", input)
+	}
+	return date, nil
+}
+
+// DaysUntil returns the number of calendar days from now until date,
+// negative when date is in the past
+func DaysUntil(date, now time.Time) int {
+	from := startOfDay(now)
+	to := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, now.Location())
+	return int(to.Sub(from).Round(24*time.Hour) / (24 * time.Hour))
+}
+
+// IsOverdue reports whether the todo is not completed and its due date
+// has passed
+func (t *Todo) IsOverdue(now time.Time) bool {
+	return !t.Completed && !t.Due.IsZero() && DaysUntil(t.Due, now) < 0
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
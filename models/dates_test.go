@@ -0,0 +1,98 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	now := time.Date(2026, 10, 17, 15, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"today", "2026-10-17"},
+		{" Tomorrow ", "2026-10-18"},
+		{"+3d", "2026-10-20"},
+		{"+2w", "2026-10-31"},
+		{"+1m", "2026-11-17"},
+		{"+0d", "2026-10-17"},
+		{"2027-01-05", "2027-01-05"},
+		{"2028-02-29", "2028-02-29"},
+		{"2026-02-30", ""},
+		{"2026-13-01", ""},
+		{"17/10/2026", ""},
+		{"+d", ""},
+		{"+3y", ""},
+		{"next week", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			date, err := ParseDate(tt.input, now)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("ParseDate(%q) = %v, want an error", tt.input, date)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseDate(%q): %v", tt.input, err)
+			}
+			if got := date.Format("2006-01-02 15:04"); got != tt.want+" 00:00" {
+				t.Errorf("ParseDate(%q) = %s, want %s at midnight", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDaysUntil(t *testing.T) {
+	now := time.Date(2026, 10, 17, 15, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		date time.Time
+		want int
+	}{
+		{"today, earlier", time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC), 0},
+		{"today, later", time.Date(2026, 10, 17, 23, 59, 0, 0, time.UTC), 0},
+		{"tomorrow", time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC), 1},
+		{"yesterday", time.Date(2026, 10, 16, 23, 59, 0, 0, time.UTC), -1},
+		{"next month", time.Date(2026, 11, 17, 0, 0, 0, 0, time.UTC), 31},
+		{"other zone", time.Date(2026, 10, 18, 1, 0, 0, 0, time.FixedZone("", 3*60*60)), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DaysUntil(tt.date, now); got != tt.want {
+				t.Errorf("DaysUntil(%v) = %d, want %d", tt.date, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsOverdue(t *testing.T) {
+	now := time.Date(2026, 10, 17, 15, 30, 0, 0, time.UTC)
+	today := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		todo Todo
+		want bool
+	}{
+		{"no due date", Todo{}, false},
+		{"due today", Todo{Due: today}, false},
+		{"due yesterday", Todo{Due: today.AddDate(0, 0, -1)}, true},
+		{"completed", Todo{Due: today.AddDate(0, 0, -1), Completed: true}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.todo.IsOverdue(now); got != tt.want {
+				t.Errorf("IsOverdue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"math/big"
+	"strconv"
+)
+
+// idLength is the length of generated todo IDs
+const idLength = 6
+
+const (
+	idLetters = "abcdefghijklmnopqrstuvwxyz"
+	idChars   = idLetters + "0123456789"
+)
+
+// NewID returns a random todo ID. IDs start with a letter, so they are
+// never mistaken for the position of a todo
+func NewID() string {
+	id := make([]byte, idLength)
+	for i := range id {
+		chars := idChars
+		if i == 0 {
+			chars = idLetters
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+		if err != nil {
+			panic(err)
+		}
+		id[i] = chars[n.Int64()]
+	}
+	return string(id)
+}
+
+// EnsureIDs gives a new ID to every todo of the project, archived ones
+// included, that has none or shares its ID with an earlier todo. The IDs
+// are derived from the filename and the todo title, so a todo written
+// without one gets the same ID on every load until its project is saved.
+// It reports whether any ID was assigned
+func (p *Project) EnsureIDs() bool {
+	seen := make(map[string]bool)
+	changed := false
+
+	var ensure func(todos []Todo)
+	ensure = func(todos []Todo) {
+		for i := range todos {
+			todo := &todos[i]
+			if todo.ID == "" || seen[todo.ID] {
+				todo.ID = derivedID(p.Filename+"\x00"+todo.Title, seen)
+				changed = true
+			}
+			seen[todo.ID] = true
+			ensure(todo.Children)
+		}
+	}
+	ensure(p.Todos)
+	ensure(p.Archive)
+	return changed
+}
+
+// uniqueID returns a new ID missing from seen
+func uniqueID(seen map[string]bool) string {
+	id := NewID()
+	for seen[id] {
+		id = NewID()
+	}
+	return id
+}
+
+// derivedID returns an ID missing from seen computed from key
+func derivedID(key string, seen map[string]bool) string {
+	for n := 0; ; n++ {
+		sum := sha256.Sum256([]byte(key + "\x00" + strconv.Itoa(n)))
+		id := make([]byte, idLength)
+		for i := range id {
+			chars := idChars
+			if i == 0 {
+				chars = idLetters
+			}
+			id[i] = chars[int(sum[i])%len(chars)]
+		}
+		if !seen[string(id)] {
+			return string(id)
+		}
+	}
+}
+
+// FindByID returns the slice holding the todo with the given ID and its
+// index in that slice, or nil when no todo of the project has that ID.
+// Archived todos are not searched
+func (p *Project) FindByID(id string) (*[]Todo, int) {
+	return findByID(&p.Todos, id)
+}
+
+func findByID(todos *[]Todo, id string) (*[]Todo, int) {
+	for i := range *todos {
+		if (*todos)[i].ID == id {
+			return todos, i
+		}
+		if siblings, index := findByID(&(*todos)[i].Children, id); siblings != nil {
+			return siblings, index
+		}
+	}
+	return nil, 0
+}
@@ -0,0 +1,84 @@
+package models
+
+import "testing"
+
+func idProject(filename string) Project {
+	return Project{
+		Name:     "Work",
+		Filename: filename,
+		Todos: []Todo{
+			{Title: "a", Children: []Todo{{Title: "a"}, {Title: "b", ID: "kept01"}}},
+			{Title: "a"},
+			{Title: "c", ID: "kept01"},
+		},
+		Archive: []Todo{{Title: "a"}},
+	}
+}
+
+func collectIDs(todos []Todo, ids []string) []string {
+	for _, todo := range todos {
+		ids = append(ids, todo.ID)
+		ids = collectIDs(todo.Children, ids)
+	}
+	return ids
+}
+
+func TestEnsureIDs(t *testing.T) {
+	project := idProject("work.md")
+	if !project.EnsureIDs() {
+		t.Fatal("EnsureIDs() = false, want IDs assigned")
+	}
+	ids := collectIDs(project.Archive, collectIDs(project.Todos, nil))
+
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		if len(id) != idLength || id[0] < 'a' || id[0] > 'z' {
+			t.Errorf("ID %q is not %d characters starting with a letter", id, idLength)
+		}
+		if seen[id] {
+			t.Errorf("ID %q is given to several todos", id)
+		}
+		seen[id] = true
+	}
+	if id := project.Todos[0].Children[1].ID; id != "kept01" {
+		t.Errorf("existing ID replaced by %q", id)
+	}
+	if id := project.Todos[2].ID; id == "kept01" {
+		t.Error("duplicate ID kept on the later todo")
+	}
+
+	// Loading the same file again gives the same IDs until they are saved
+	again := idProject("work.md")
+	again.EnsureIDs()
+	for i, id := range collectIDs(again.Archive, collectIDs(again.Todos, nil)) {
+		if id != ids[i] {
+			t.Errorf("ID %d = %q on reload, want %q", i, id, ids[i])
+		}
+	}
+
+	if project.EnsureIDs() {
+		t.Error("EnsureIDs() = true for todos that all have an ID")
+	}
+
+	other := idProject("home.md")
+	other.EnsureIDs()
+	if other.Todos[0].ID == project.Todos[0].ID {
+		t.Errorf("the same title in another project got the same ID %q", other.Todos[0].ID)
+	}
+}
+
+func TestFindByID(t *testing.T) {
+	project := idProject("work.md")
+	project.EnsureIDs()
+
+	todos, i := project.FindByID("kept01")
+	if todos == nil || (*todos)[i].Title != "b" {
+		t.Errorf("FindByID(kept01) = %v, %d, want the subtask b", todos, i)
+	}
+	if todos, _ := project.FindByID(project.Archive[0].ID); todos != nil {
+		t.Error("FindByID found an archived todo")
+	}
+	if todos, _ := project.FindByID("zzzzzz"); todos != nil {
+		t.Error("FindByID found a missing ID")
+	}
+}
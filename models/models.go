@@ -3,44 +3,87 @@ package models
 import (
 	"fmt"
 	"path/filepath"
-	"sort"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Priority ranks todos, PriorityNone sitting between medium and low as
+// in Obsidian Tasks
+type Priority int
+
+const (
+	PriorityLowest Priority = iota - 2
+	PriorityLow
+	PriorityNone
+	PriorityMedium
+	PriorityHigh
+	PriorityHighest
 )
 
 type Todo struct {
+	// ID identifies the todo within its project across edits and moves.
+	// It is stored as a trailing ^id block reference
+	ID        string
 	Title     string
 	Completed bool
-	LineNum   int
-	CreatedAt time.Time
+	Priority  Priority
+	// Tags and Contexts are the lowercased #tags and @contexts found in
+	// Title, without their prefix. They are kept in sync by SetTitle
+	Tags        []string
+	Contexts    []string
+	LineNum     int
+	CreatedAt   time.Time
+	CompletedAt time.Time
+	// Due and Scheduled are zero when the todo has no such date
+	Due       time.Time
+	Scheduled time.Time
+	// Notes is a free text description, possibly spanning several lines
+	Notes    string
+	Children []Todo
+	// Collapsed hides the subtasks in the TUI. It is not persisted.
+	Collapsed bool
 }
 
 type Project struct {
 	Name     string
 	Filename string
 	Todos    []Todo
+	// Archive holds the completed todos moved out of the list
+	Archive []Todo
+	// Dirty marks a project modified since it was loaded or last saved.
+	// It is not persisted.
+	Dirty bool
 }
 
 type AppData struct {
 	Projects       []Project
 	CurrentProject int
+	// Warnings lists problems found while loading that did not prevent
+	// it, such as files left by an interrupted save.
+	Warnings []string
 }
 
 func NewTodo(title string) Todo {
-	return Todo{
-		Title:     title,
+	todo := Todo{
+		ID:        NewID(),
 		Completed: false,
 		LineNum:   -1,
 		CreatedAt: time.Now(),
 	}
+	todo.SetTitle(title)
+	return todo
 }
 
-func NewProject(name string) Project {
-	filename := generateFilename(name)
+// NewProject returns a project named name, stored in filename, e.g. one
+// given by ProjectFilename
+func NewProject(name, filename string) Project {
 	return Project{
 		Name:     name,
 		Filename: filename,
 		Todos:    []Todo{},
+		Dirty:    true,
 	}
 }
 
@@ -51,43 +94,295 @@ func NewAppData() AppData {
 	}
 }
 
-func generateFilename(name string) string {
-	safe := strings.ToLower(name)
-	safe = strings.ReplaceAll(safe, " ", "-")
-	safe = strings.ReplaceAll(safe, "_", "-")
+// ProjectFilename returns the filename for a project named name: the
+// name slugified with its letters transliterated to ASCII, or kept when
+// keepUnicode is set. When taken reports the filename as used, a numeric
+// suffix is added, e.g. "api-v2-2.md"
+func ProjectFilename(name string, keepUnicode bool, taken func(filename string) bool) string {
+	slug := generateFilename(name, keepUnicode)
+	filename := slug + ".md"
+	for n := 2; taken != nil && taken(filename); n++ {
+		filename = fmt.Sprintf("%s-%d.md", slug, n)
+	}
+	return filename
+}
 
+// maxSlugLength keeps filenames, in bytes, well below the limit of most
+// file systems
+const maxSlugLength = 100
+
+// generateFilename returns the lowercase words of name joined by dashes.
+// Letters with an ASCII transliteration, e.g. "é" or "ж", are replaced by
+// it unless keepUnicode is set; other characters separate words
+func generateFilename(name string, keepUnicode bool) string {
 	var result strings.Builder
-	for _, r := range safe {
-		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' {
-			result.WriteRune(r)
+	separate := false
+	for _, r := range strings.ToLower(name) {
+		var part string
+		switch {
+		case r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			part = string(r)
+		case r == '\'' || r == '’':
+			// "Tom's list" reads better as "toms-list"
+			continue
+		case unicode.Is(unicode.Mn, r):
+			// Combining accents belong to the previous letter
+			if keepUnicode && result.Len() > 0 && !separate {
+				result.WriteRune(r)
+			}
+			continue
+		case keepUnicode && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			part = string(r)
+		default:
+			if ascii, ok := transliterations[r]; ok {
+				if ascii == "" {
+					// Signs such as the Cyrillic soft sign have no sound
+					continue
+				}
+				part = ascii
+			}
 		}
-	}
 
-	filename := result.String()
-	if filename == "" {
-		filename = fmt.Sprintf("project-%d", time.Now().Unix())
+		if part == "" {
+			separate = result.Len() > 0
+			continue
+		}
+		if result.Len()+len(part)+1 > maxSlugLength {
+			break
+		}
+		if separate {
+			result.WriteByte('-')
+			separate = false
+		}
+		result.WriteString(part)
 	}
 
-	return filename + ".md"
+	if result.Len() == 0 {
+		return "project"
+	}
+	return result.String()
 }
 
 func (p *Project) GetFilePath(donutDir string) string {
 	return filepath.Join(donutDir, p.Filename)
 }
 
-// SortTodos sorts todos with completed tasks at the bottom (muted)
-// and within each group, sorts by creation date (latest first)
-func (p *Project) SortTodos() {
-	sort.SliceStable(p.Todos, func(i, j int) bool {
-		todoI := &p.Todos[i]
-		todoJ := &p.Todos[j]
+// MarkCompleted marks the todo as done or not done, recording when it was
+// completed
+func (t *Todo) MarkCompleted(completed bool) {
+	if completed && !t.Completed {
+		t.CompletedAt = time.Now()
+	} else if !completed {
+		t.CompletedAt = time.Time{}
+	}
+	t.Completed = completed
+}
+
+// ParsePriority returns the priority named by s: highest, high, medium,
+// none, low or lowest
+func ParsePriority(s string) (Priority, error) {
+	switch strings.ToLower(s) {
+	case "highest":
+		return PriorityHighest, nil
+	case "high":
+		return PriorityHigh, nil
+	case "medium":
+		return PriorityMedium, nil
+	case "none", "":
+		return PriorityNone, nil
+	case "low":
+		return PriorityLow, nil
+	case "lowest":
+		return PriorityLowest, nil
+	}
+	return PriorityNone, fmt.Errorf("invalid priority %q, use highest, high, medium, none, low or lowest", s)
+}
+
+// String returns the name of the priority, as accepted by ParsePriority
+func (p Priority) String() string {
+	switch p {
+	case PriorityHighest:
+		return "highest"
+	case PriorityHigh:
+		return "high"
+	case PriorityMedium:
+		return "medium"
+	case PriorityLow:
+		return "low"
+	case PriorityLowest:
+		return "lowest"
+	}
+	return "none"
+}
+
+// Clone returns a deep copy of the todo and its subtasks
+func (t Todo) Clone() Todo {
+	t.Tags = append([]string(nil), t.Tags...)
+	t.Contexts = append([]string(nil), t.Contexts...)
+	if t.Children != nil {
+		children := make([]Todo, len(t.Children))
+		for i := range t.Children {
+			children[i] = t.Children[i].Clone()
+		}
+		t.Children = children
+	}
+	return t
+}
+
+// Clone returns a deep copy of the project and its todos
+func (p Project) Clone() Project {
+	todos := make([]Todo, len(p.Todos))
+	for i := range p.Todos {
+		todos[i] = p.Todos[i].Clone()
+	}
+	p.Todos = todos
+
+	if p.Archive != nil {
+		archive := make([]Todo, len(p.Archive))
+		for i := range p.Archive {
+			archive[i] = p.Archive[i].Clone()
+		}
+		p.Archive = archive
+	}
+	return p
+}
+
+// RaisePriority moves the todo one priority level up, up to PriorityHighest
+func (t *Todo) RaisePriority() {
+	if t.Priority < PriorityHighest {
+		t.Priority++
+	}
+}
+
+// LowerPriority moves the todo one priority level down, down to PriorityLowest
+func (t *Todo) LowerPriority() {
+	if t.Priority > PriorityLowest {
+		t.Priority--
+	}
+}
+
+// SetCompleted marks the todo and all of its subtasks as done or not done
+func (t *Todo) SetCompleted(completed bool) {
+	t.MarkCompleted(completed)
+	for i := range t.Children {
+		t.Children[i].SetCompleted(completed)
+	}
+}
+
+// CountTodos returns the number of completed todos and the total number
+// of todos, subtasks included
+func CountTodos(todos []Todo) (completed, total int) {
+	for _, todo := range todos {
+		if todo.Completed {
+			completed++
+		}
+		childCompleted, childTotal := CountTodos(todo.Children)
+		completed += childCompleted
+		total += childTotal + 1
+	}
+	return completed, total
+}
+
+// SyncCompletion marks every todo that has subtasks as completed when all
+// of its subtasks are completed, and as not completed otherwise
+func (p *Project) SyncCompletion() {
+	syncCompletion(p.Todos)
+}
+
+func syncCompletion(todos []Todo) {
+	for i := range todos {
+		todo := &todos[i]
+		if len(todo.Children) == 0 {
+			continue
+		}
+		syncCompletion(todo.Children)
+
+		completed := true
+		for _, child := range todo.Children {
+			if !child.Completed {
+				completed = false
+				break
+			}
+		}
+		todo.MarkCompleted(completed)
+	}
+}
+
+// SortMode selects the order todos are shown in
+type SortMode int
+
+const (
+	SortByCreated SortMode = iota
+	SortByDue
+	// SortManual keeps the order of the project file
+	SortManual
+	SortByPriority
+	SortByTitle
+)
+
+// SortModes lists every sort mode, in the order the TUI cycles through them
+var SortModes = []SortMode{SortManual, SortByCreated, SortByDue, SortByPriority, SortByTitle}
 
-		// If completion status differs, incomplete tasks come first
-		if todoI.Completed != todoJ.Completed {
-			return !todoI.Completed
+// ParseSortMode returns the sort mode named by s, as returned by String,
+// defaulting to SortByCreated
+func ParseSortMode(s string) SortMode {
+	for _, mode := range SortModes {
+		if mode.String() == s {
+			return mode
 		}
+	}
+	return SortByCreated
+}
+
+// String returns the name of the sort mode, as accepted by ParseSortMode
+func (mode SortMode) String() string {
+	switch mode {
+	case SortManual:
+		return "manual"
+	case SortByDue:
+		return "due"
+	case SortByPriority:
+		return "priority"
+	case SortByTitle:
+		return "alphabetical"
+	}
+	return "created"
+}
+
+// Less reports whether a comes before b. Except in SortManual, where no
+// todo comes before another, completed todos come last. SortByCreated and
+// SortByDue then order todos by priority, followed by creation date
+// (latest first) or by nearest due date, todos without a due date last.
+// SortByPriority keeps todos of the same priority in file order, and
+// SortByTitle orders todos by title, ignoring case
+func (mode SortMode) Less(a, b *Todo) bool {
+	if mode == SortManual {
+		return false
+	}
+
+	// If completion status differs, incomplete tasks come first
+	if a.Completed != b.Completed {
+		return !a.Completed
+	}
 
-		// Within the same completion status, sort by creation date (latest first)
-		return todoI.CreatedAt.After(todoJ.CreatedAt)
-	})
-}
\ No newline at end of file
+	if mode == SortByTitle {
+		return strings.ToLower(a.Title) < strings.ToLower(b.Title)
+	}
+
+	if a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+	if mode == SortByPriority {
+		return false
+	}
+
+	if mode == SortByDue && !a.Due.Equal(b.Due) {
+		if a.Due.IsZero() || b.Due.IsZero() {
+			return b.Due.IsZero()
+		}
+		return a.Due.Before(b.Due)
+	}
+
+	// Within the same completion status, sort by creation date (latest first)
+	return a.CreatedAt.After(b.CreatedAt)
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestProjectFilename(t *testing.T) {
+	tests := []struct {
+		name        string
+		keepUnicode bool
+		taken       []string
+		want        string
+	}{
+		{name: "Work", want: "work.md"},
+		{name: "Café", want: "cafe.md"},
+		{name: "Cafe\u0301", want: "cafe.md"},
+		{name: "Café", keepUnicode: true, want: "café.md"},
+		{name: "Cafe\u0301 au lait", keepUnicode: true, want: "cafe\u0301-au-lait.md"},
+		{name: "Tom's list", want: "toms-list.md"},
+		{name: "Проект", want: "proekt.md"},
+		{name: "日本", want: "project.md"},
+		{name: "日本", keepUnicode: true, want: "日本.md"},
+		{name: "!!!", want: "project.md"},
+		{name: "API v2", taken: []string{"api-v2.md"}, want: "api-v2-2.md"},
+		{name: "api-v2", taken: []string{"api-v2.md", "api-v2-2.md"}, want: "api-v2-3.md"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			taken := func(filename string) bool {
+				return slices.Contains(tt.taken, filename)
+			}
+			if got := ProjectFilename(tt.name, tt.keepUnicode, taken); got != tt.want {
+				t.Errorf("ProjectFilename(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package models
+
+// MoveTodo removes the todo at index of todos, one of the lists of another
+// project, and appends it along with its subtasks and metadata to the todos
+// of p. Todos keep their ID unless p already has a todo with the same ID
+func (p *Project) MoveTodo(todos *[]Todo, index int) {
+	if index < 0 || index >= len(*todos) {
+		return
+	}
+	todo := (*todos)[index]
+	*todos = append((*todos)[:index], (*todos)[index+1:]...)
+	p.adopt(todo, false)
+}
+
+// CopyTodo appends a copy of the todo and its subtasks to the todos of p.
+// The copies get new IDs, as they are different todos from then on
+func (p *Project) CopyTodo(todo Todo) {
+	p.adopt(todo.Clone(), true)
+}
+
+func (p *Project) adopt(todo Todo, newIDs bool) {
+	seen := make(map[string]bool)
+	var collect func(todos []Todo)
+	collect = func(todos []Todo) {
+		for i := range todos {
+			seen[todos[i].ID] = true
+			collect(todos[i].Children)
+		}
+	}
+	collect(p.Todos)
+	collect(p.Archive)
+
+	var adopt func(todo *Todo)
+	adopt = func(todo *Todo) {
+		// Line numbers point into the file of the other project
+		todo.LineNum = -1
+		if newIDs || todo.ID == "" || seen[todo.ID] {
+			todo.ID = uniqueID(seen)
+		}
+		seen[todo.ID] = true
+		for i := range todo.Children {
+			adopt(&todo.Children[i])
+		}
+	}
+	adopt(&todo)
+	p.Todos = append(p.Todos, todo)
+}
@@ -0,0 +1,39 @@
+package models
+
+import "strings"
+
+// SetNotes sets the notes of the todo. Trailing spaces, blank lines around
+// the notes and the indentation shared by all their lines are dropped, as
+// the notes are written indented under the todo
+func (t *Todo) SetNotes(notes string) {
+	notes = strings.ReplaceAll(notes, "\r\n", "\n")
+	lines := strings.Split(notes, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	prefix := ""
+	for i, line := range lines {
+		if line == "" {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if i == 0 {
+			prefix = indent
+		}
+		for !strings.HasPrefix(indent, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, prefix)
+	}
+
+	t.Notes = strings.Join(lines, "\n")
+}
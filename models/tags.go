@@ -0,0 +1,71 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+)
+
+// TagRegex matches #tag and @context tokens in a todo title. The first
+// group is the prefix and the second one the name.
+var TagRegex = regexp.MustCompile(`(?:^|\s)([#@])([\p{L}\p{N}_/-]*[\p{L}_/-][\p{L}\p{N}_/-]*)`)
+
+// SetTitle sets the title of the todo and extracts the #tags and
+// @contexts it contains
+func (t *Todo) SetTitle(title string) {
+	t.Title = title
+	t.Tags = nil
+	t.Contexts = nil
+
+	for _, matches := range TagRegex.FindAllStringSubmatch(title, -1) {
+		name := strings.ToLower(matches[2])
+		if matches[1] == "#" {
+			t.Tags = appendUnique(t.Tags, name)
+		} else {
+			t.Contexts = appendUnique(t.Contexts, name)
+		}
+	}
+}
+
+// HasTag reports whether the todo carries tag, given with its prefix
+// as "#tag" or "@context"
+func (t *Todo) HasTag(tag string) bool {
+	if len(tag) < 2 {
+		return false
+	}
+
+	names := t.Tags
+	if tag[0] == '@' {
+		names = t.Contexts
+	}
+
+	name := strings.ToLower(tag[1:])
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// CountTags returns how many todos carry each tag and context, keyed with
+// their prefix, subtasks included
+func CountTags(todos []Todo, counts map[string]int) {
+	for _, todo := range todos {
+		for _, tag := range todo.Tags {
+			counts["#"+tag]++
+		}
+		for _, context := range todo.Contexts {
+			counts["@"+context]++
+		}
+		CountTags(todo.Children, counts)
+	}
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
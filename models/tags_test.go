@@ -0,0 +1,66 @@
+package models
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSetTitleTags(t *testing.T) {
+	tests := []struct {
+		title    string
+		tags     []string
+		contexts []string
+	}{
+		{"Call Bob", nil, nil},
+		{"#urgent fix the build", []string{"urgent"}, nil},
+		{"Review PRs #backend @office", []string{"backend"}, []string{"office"}},
+		{"Tags #Work and #work once", []string{"work"}, nil},
+		{"Ends with punctuation #home.", []string{"home"}, nil},
+		{"In parentheses (#home)", nil, nil},
+		{"Nested #work/reviews and #multi-word_tag", []string{"work/reviews", "multi-word_tag"}, nil},
+		{"Issue #123 is not a tag, #v2 is", []string{"v2"}, nil},
+		{"Read https://example.com/page#section", nil, nil},
+		{"Mail bob@example.com", nil, nil},
+		{"## Heading", nil, nil},
+		{"C# and a lone # sign", nil, nil},
+		{"Buy #café for @maison", []string{"café"}, []string{"maison"}},
+		{"Learn #日本語 @東京", []string{"日本語"}, []string{"東京"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			var todo Todo
+			todo.SetTitle(tt.title)
+			if !slices.Equal(todo.Tags, tt.tags) {
+				t.Errorf("tags = %q, want %q", todo.Tags, tt.tags)
+			}
+			if !slices.Equal(todo.Contexts, tt.contexts) {
+				t.Errorf("contexts = %q, want %q", todo.Contexts, tt.contexts)
+			}
+		})
+	}
+}
+
+func TestHasTag(t *testing.T) {
+	var todo Todo
+	todo.SetTitle("Review PRs #Backend @office")
+
+	tests := []struct {
+		tag  string
+		want bool
+	}{
+		{"#backend", true},
+		{"#BACKEND", true},
+		{"@office", true},
+		{"#office", false},
+		{"backend", false},
+		{"#", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := todo.HasTag(tt.tag); got != tt.want {
+			t.Errorf("HasTag(%q) = %v, want %v", tt.tag, got, tt.want)
+		}
+	}
+}
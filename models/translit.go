@@ -0,0 +1,89 @@
+package models
+
+// transliterations spell lowercase letters of Latin, Greek and Cyrillic
+// scripts in ASCII, for filenames
+var transliterations = map[rune]string{
+	// Latin
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ă': "a", 'ą': "a", 'ǎ': "a",
+	'æ': "ae", 'ǽ': "ae",
+	'ç': "c", 'ć': "c", 'ĉ': "c", 'ċ': "c", 'č': "c",
+	'ď': "d", 'đ': "d", 'ð': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ĕ': "e", 'ė': "e", 'ę': "e", 'ě': "e",
+	'ĝ': "g", 'ğ': "g", 'ġ': "g", 'ģ': "g",
+	'ĥ': "h", 'ħ': "h",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ĩ': "i", 'ī': "i", 'ĭ': "i", 'į': "i", 'ı': "i",
+	'ĳ': "ij",
+	'ĵ': "j",
+	'ķ': "k",
+	'ĺ': "l", 'ļ': "l", 'ľ': "l", 'ŀ': "l", 'ł': "l",
+	'ñ': "n", 'ń': "n", 'ņ': "n", 'ň': "n", 'ŉ': "n",
+	'ŋ': "ng",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ŏ': "o", 'ő': "o", 'ǒ': "o",
+	'œ': "oe",
+	'ŕ': "r", 'ŗ': "r", 'ř': "r",
+	'ś': "s", 'ŝ': "s", 'ş': "s", 'š': "s", 'ș': "s",
+	'ß': "ss",
+	'ţ': "t", 'ť': "t", 'ŧ': "t", 'ț': "t",
+	'þ': "th",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ũ': "u", 'ū': "u", 'ŭ': "u", 'ů': "u", 'ű': "u", 'ų': "u", 'ǔ': "u",
+	'ŵ': "w",
+	'ý': "y", 'ÿ': "y", 'ŷ': "y",
+	'ź': "z", 'ż': "z", 'ž': "z",
+
+	// Greek
+	'α': "a", 'ά': "a",
+	'β': "v",
+	'γ': "g",
+	'δ': "d",
+	'ε': "e", 'έ': "e",
+	'ζ': "z",
+	'η': "i", 'ή': "i", 'ι': "i", 'ί': "i", 'ϊ': "i", 'ΐ': "i",
+	'θ': "th",
+	'κ': "k",
+	'λ': "l",
+	'μ': "m",
+	'ν': "n",
+	'ξ': "x",
+	'ο': "o", 'ό': "o", 'ω': "o", 'ώ': "o",
+	'π': "p",
+	'ρ': "r",
+	'σ': "s", 'ς': "s",
+	'τ': "t",
+	'υ': "y", 'ύ': "y", 'ϋ': "y", 'ΰ': "y",
+	'φ': "f",
+	'χ': "ch",
+	'ψ': "ps",
+
+	// Cyrillic
+	'а': "a",
+	'б': "b",
+	'в': "v",
+	'г': "g", 'ґ': "g",
+	'д': "d",
+	'е': "e", 'ё': "e", 'э': "e",
+	'ж': "zh",
+	'з': "z",
+	'и': "i", 'і': "i",
+	'й': "y", 'ы': "y",
+	'к': "k",
+	'л': "l",
+	'м': "m",
+	'н': "n",
+	'о': "o",
+	'п': "p",
+	'р': "r",
+	'с': "s",
+	'т': "t",
+	'у': "u",
+	'ф': "f",
+	'х': "kh",
+	'ц': "ts",
+	'ч': "ch",
+	'ш': "sh",
+	'щ': "shch",
+	'ъ': "", 'ь': "",
+	'ю': "yu",
+	'я': "ya",
+	'ї': "yi",
+	'є': "ye",
+}
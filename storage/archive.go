@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"time"
+
+	"donut/models"
+)
+
+// ArchiveExpired archives the todos of every project completed more than
+// afterDays days ago, and saves the projects that changed. An afterDays of
+// 0 leaves todos until they are archived by hand. It returns the number of
+// todos archived.
+func ArchiveExpired(backend Backend, data *models.AppData, afterDays int, now time.Time) (int, error) {
+	if afterDays <= 0 {
+		return 0, nil
+	}
+
+	cutoff := now.AddDate(0, 0, -afterDays)
+	archived := 0
+	for i := range data.Projects {
+		project := &data.Projects[i]
+		n := project.ArchiveCompleted(cutoff)
+		if n == 0 {
+			continue
+		}
+		project.Dirty = true
+		if err := backend.SaveProject(project); err != nil {
+			return archived, err
+		}
+		archived += n
+	}
+	return archived, nil
+}
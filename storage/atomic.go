@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tempSuffix ends the name of the temporary files written by
+// writeFileAtomic, so that leftovers of an interrupted save can be found.
+const tempSuffix = ".donut-tmp"
+
+// writeFileAtomic replaces the file at path with data. The data is written
+// to a temporary file in the same directory, synced and renamed over the
+// original, so a crash or a full disk leaves either the old or the new
+// content but never a truncated file. The mode of an existing file is
+// preserved, new files are created with perm.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir, name := filepath.Split(path)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+name+".*"+tempSuffix)
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	// Until the rename succeeds the temporary file is ours to clean up
+	renamed := false
+	defer func() {
+		if !renamed {
+			tmp.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	renamed = true
+
+	syncDir(dir)
+	return nil
+}
+
+// syncDir flushes the directory entry of a rename to disk. Not every
+// platform supports syncing a directory, so failures are ignored.
+func syncDir(dir string) {
+	if dir == "" {
+		dir = "."
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+	d.Sync()
+}
+
+// isTempFile reports whether name is a temporary file left by
+// writeFileAtomic.
+func isTempFile(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.HasSuffix(name, tempSuffix)
+}
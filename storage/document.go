@@ -0,0 +1,586 @@
+package storage
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"donut/models"
+)
+
+type lineKind int
+
+const (
+	textLine lineKind = iota
+	titleLine
+	todoLine
+	noteLine
+)
+
+var (
+	titleRegex   = regexp.MustCompile(`^#\s+(.+)$`)
+	todoRegex    = regexp.MustCompile(`^(\s*)-\s+\[([ x])\]\s+(.+)$`)
+	archiveRegex = regexp.MustCompile(`^##\s+Archive\s*$`)
+	// checkboxNoteRegex matches the note lines that would read as todos,
+	// which are written with a backslash before the dash, and the lines
+	// already starting with backslashes, which get one more
+	checkboxNoteRegex = regexp.MustCompile(`^(\s*)(\\*-\s+\[[ x]\])`)
+)
+
+// archiveHeading starts the section of a project file holding archived
+// todos. Everything from the heading to the end of the file belongs to
+// the archive.
+const archiveHeading = "## Archive"
+
+// defaultIndent is used to nest subtasks when the file does not already
+// contain any indented todo to take the indentation from.
+const defaultIndent = "  "
+
+// docLine is a single line of a project file. Todo lines keep the todo
+// they were parsed into, without its subtasks, so unchanged todos can be
+// written back verbatim. parent is the line number of the parent todo, or
+// 0 for top-level todos. notes holds the original text of the note lines
+// following a todo line, which are written along with it.
+type docLine struct {
+	text   string
+	kind   lineKind
+	title  string
+	todo   models.Todo
+	indent string
+	depth  int
+	parent int
+	notes  []string
+}
+
+// document is the parsed form of a project markdown file. Every line is
+// kept, so prose, headings and blank lines survive a load/save round-trip
+// and only the lines of todos that actually changed are rewritten.
+type document struct {
+	lines           []docLine
+	indent          string
+	crlf            bool
+	trailingNewline bool
+
+	// section marks the archive section of a file, which has no title
+	section bool
+	// archiveFile marks an archive kept in a file of its own
+	archiveFile bool
+	// archive holds the archived todos of the project
+	archive *document
+}
+
+func parseDocument(content string) *document {
+	crlf := strings.Contains(content, "\r\n")
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		if archiveRegex.MatchString(line) {
+			doc := parseSection(strings.Join(lines[:i], "\n")+"\n", crlf, false)
+			doc.archive = parseSection(strings.Join(lines[i:], "\n"), crlf, true)
+			return doc
+		}
+	}
+	return parseSection(content, crlf, false)
+}
+
+// parseArchiveFile parses a separate archive file.
+func parseArchiveFile(content string) *document {
+	archive := parseDocument(content)
+	archive.archiveFile = true
+	return archive
+}
+
+// parseSection parses content, whose line endings are already normalized.
+func parseSection(content string, crlf, section bool) *document {
+	doc := &document{
+		indent:          defaultIndent,
+		crlf:            crlf,
+		trailingNewline: true,
+		section:         section,
+	}
+	if content == "" || content == "\n" {
+		return doc
+	}
+
+	doc.trailingNewline = strings.HasSuffix(content, "\n")
+	content = strings.TrimSuffix(content, "\n")
+
+	// stack holds the line numbers of the todos enclosing the current line
+	var stack []int
+	// noteOwner is the line number of the todo that indented prose on the
+	// current line belongs to. Blank lines are only part of the notes when
+	// more notes follow them
+	noteOwner := 0
+	var blanks []int
+	hasTitle := false
+	indentFound := false
+	for i, text := range strings.Split(content, "\n") {
+		line := docLine{text: text}
+		if matches := titleRegex.FindStringSubmatch(text); matches != nil && !hasTitle && !section {
+			line.kind = titleLine
+			line.title = matches[1]
+			hasTitle = true
+			stack = nil
+			noteOwner = 0
+		} else if matches := todoRegex.FindStringSubmatch(text); matches != nil {
+			line.kind = todoLine
+			line.indent = matches[1]
+			line.todo = parseTodoText(matches[3])
+			line.todo.Completed = matches[2] == "x"
+			line.todo.LineNum = i + 1
+
+			width := indentWidth(line.indent)
+			for len(stack) > 0 && indentWidth(doc.lines[stack[len(stack)-1]-1].indent) >= width {
+				stack = stack[:len(stack)-1]
+			}
+			if len(stack) > 0 {
+				parent := doc.lines[stack[len(stack)-1]-1]
+				line.parent = parent.todo.LineNum
+				line.depth = parent.depth + 1
+				if !indentFound {
+					doc.indent = strings.TrimPrefix(line.indent, parent.indent)
+					indentFound = true
+				}
+			}
+			stack = append(stack, i+1)
+			noteOwner = i + 1
+			blanks = nil
+		} else if strings.TrimSpace(text) == "" {
+			if noteOwner != 0 {
+				blanks = append(blanks, i)
+			}
+		} else if noteOwner != 0 && indentWidth(text) > indentWidth(doc.lines[noteOwner-1].indent) {
+			for _, blank := range blanks {
+				doc.lines[blank].kind = noteLine
+				doc.lines[blank].parent = noteOwner
+			}
+			blanks = nil
+			line.kind = noteLine
+			line.parent = noteOwner
+		} else {
+			noteOwner = 0
+			if indentWidth(text) == 0 {
+				// Unindented prose ends any list, so following indented
+				// todos are not subtasks of the todos above it
+				stack = nil
+			}
+		}
+		doc.lines = append(doc.lines, line)
+	}
+
+	for _, line := range doc.lines {
+		if line.kind == noteLine {
+			owner := &doc.lines[line.parent-1]
+			owner.notes = append(owner.notes, line.text)
+		}
+	}
+	for i := range doc.lines {
+		if line := &doc.lines[i]; line.kind == todoLine {
+			notes := make([]string, len(line.notes))
+			for j, note := range line.notes {
+				notes[j] = unescapeNote(note)
+			}
+			line.todo.SetNotes(strings.Join(notes, "\n"))
+		}
+	}
+
+	return doc
+}
+
+// project builds the project described by the document. The name is left
+// empty when the file has no title line.
+func (d *document) project(filename string) models.Project {
+	project := models.Project{
+		Filename: filename,
+		Todos:    []models.Todo{},
+	}
+
+	children := make(map[int][]int)
+	for i, line := range d.lines {
+		switch line.kind {
+		case titleLine:
+			project.Name = line.title
+		case todoLine:
+			children[line.parent] = append(children[line.parent], i+1)
+		}
+	}
+
+	var build func(lineNum int) models.Todo
+	build = func(lineNum int) models.Todo {
+		todo := d.lines[lineNum-1].todo
+		for _, child := range children[lineNum] {
+			todo.Children = append(todo.Children, build(child))
+		}
+		return todo
+	}
+
+	for _, lineNum := range children[0] {
+		project.Todos = append(project.Todos, build(lineNum))
+	}
+
+	if d.archive != nil {
+		project.Archive = d.archive.project(filename).Todos
+	}
+
+	return project
+}
+
+// render returns a new document holding the project's current state.
+// Todos are matched to their original lines through LineNum: unchanged
+// todos keep their line as-is, edited ones are rewritten in place and
+// removed ones are dropped. New todos, and todos moved before a sibling
+// that used to precede them, are written after the subtasks of their
+// previous sibling, or right after their parent or before the first todo
+// of the file when they come first. The LineNum of every todo is updated
+// to its position in the rendered document.
+func (d *document) render(project *models.Project) *document {
+	out := &document{
+		indent:          d.indent,
+		crlf:            d.crlf,
+		trailingNewline: d.trailingNewline,
+		section:         d.section,
+		archiveFile:     d.archiveFile,
+	}
+
+	firstTodo, lastTodo := 0, 0
+	hasTitle := false
+	for i, line := range d.lines {
+		switch line.kind {
+		case todoLine:
+			if firstTodo == 0 {
+				firstTodo = i + 1
+			}
+			lastTodo = i + 1
+		case titleLine:
+			hasTitle = true
+		}
+	}
+
+	// insertion is a new todo, written along with its subtasks after the
+	// line it is anchored to
+	type insertion struct {
+		todo  *models.Todo
+		depth int
+	}
+
+	byLine := make(map[int]*models.Todo)
+	depths := make(map[*models.Todo]int)
+	parents := make(map[*models.Todo]*models.Todo)
+	inserts := make(map[int][]insertion)
+
+	// place walks the todo tree, claiming the original line of existing
+	// todos that are still in order and anchoring the others after the
+	// last line used before them. It returns the last original line used
+	// by the subtree.
+	var place func(todos []models.Todo, depth int, parent *models.Todo, anchor int) int
+	place = func(todos []models.Todo, depth int, parent *models.Todo, anchor int) int {
+		keep := d.inOrder(todos, anchor)
+		for i := range todos {
+			todo := &todos[i]
+			parents[todo] = parent
+			if !keep[i] || byLine[todo.LineNum] != nil || todo.LineNum <= anchor {
+				inserts[anchor] = append(inserts[anchor], insertion{todo: todo, depth: depth})
+				continue
+			}
+			byLine[todo.LineNum] = todo
+			depths[todo] = depth
+			anchor = todo.LineNum
+			if last := place(todo.Children, depth+1, todo, todo.LineNum); last > anchor {
+				anchor = last
+			}
+		}
+		return anchor
+	}
+	place(project.Todos, 0, nil, max(firstTodo-1, 0))
+
+	owners := make(map[int]*models.Todo)
+	indents := make(map[*models.Todo]string)
+
+	var appendTodo func(line docLine, todo *models.Todo, depth int)
+	appendTodo = func(line docLine, todo *models.Todo, depth int) {
+		indent := strings.Repeat(d.indent, depth)
+		if line.kind == todoLine && line.depth == depth {
+			indent = line.indent
+		} else if parent := parents[todo]; parent != nil {
+			indent = indents[parent] + d.indent
+		}
+		indents[todo] = indent
+		owners[len(out.lines)] = todo
+		rendered := renderTodoLine(line, todo, depth, indent)
+		rendered.todo.Notes = todo.Notes
+		rendered.notes = renderNotes(line, todo, indent, d.indent)
+		out.lines = append(out.lines, rendered)
+		for _, note := range rendered.notes {
+			out.lines = append(out.lines, docLine{text: note, kind: noteLine})
+		}
+
+		// Subtasks of a new todo are all new as well
+		if line.kind != todoLine {
+			for i := range todo.Children {
+				parents[&todo.Children[i]] = todo
+				appendTodo(docLine{}, &todo.Children[i], depth+1)
+			}
+		}
+	}
+
+	appendInserts := func(anchor int) {
+		// Deeper todos belong to inner subtrees and must come first
+		group := inserts[anchor]
+		sort.SliceStable(group, func(i, j int) bool {
+			return group[i].depth > group[j].depth
+		})
+		for _, ins := range group {
+			appendTodo(docLine{}, ins.todo, ins.depth)
+		}
+	}
+
+	if !hasTitle && !d.section {
+		out.lines = append(out.lines, newTitleLine(project.Name))
+		if len(d.lines) == 0 || d.lines[0].text != "" {
+			out.lines = append(out.lines, docLine{})
+		}
+	}
+
+	if firstTodo == 1 {
+		appendInserts(0)
+	}
+	for i, line := range d.lines {
+		lineNum := i + 1
+		switch line.kind {
+		case titleLine:
+			if line.title != project.Name {
+				line = newTitleLine(project.Name)
+			}
+			out.lines = append(out.lines, line)
+		case todoLine:
+			if todo, ok := byLine[lineNum]; ok {
+				appendTodo(line, todo, depths[todo])
+			}
+		case noteLine:
+			// Notes are written along with their todo
+		default:
+			out.lines = append(out.lines, line)
+		}
+
+		appendInserts(lineNum)
+	}
+
+	if lastTodo == 0 && len(inserts[0]) > 0 {
+		if last := out.lines[len(out.lines)-1]; last.text != "" {
+			out.lines = append(out.lines, docLine{})
+		}
+		appendInserts(0)
+	}
+
+	for i, todo := range owners {
+		todo.LineNum = i + 1
+		out.lines[i].todo.LineNum = todo.LineNum
+	}
+	for i, todo := range owners {
+		out.lines[i].parent = 0
+		if parent := parents[todo]; parent != nil {
+			out.lines[i].parent = parent.LineNum
+		}
+		for j := range out.lines[i].notes {
+			out.lines[i+1+j].parent = todo.LineNum
+		}
+	}
+
+	if archive := d.archive; archive != nil || len(project.Archive) > 0 {
+		if archive == nil {
+			archive = out.newArchiveSection()
+		}
+		if !archive.archiveFile {
+			// The archive section follows the last line
+			out.trailingNewline = true
+		}
+		out.archive = archive.render(&models.Project{Name: project.Name + " Archive", Todos: project.Archive})
+		if !archive.archiveFile && out.archive.emptySection() {
+			// Unarchiving the last todo drops the section along with its
+			// heading
+			out.archive = nil
+			out.trailingNewline = d.trailingNewline
+			// and the blank lines that separated it from the list
+			for n := len(out.lines); n > 0 && out.lines[n-1].kind == textLine && strings.TrimSpace(out.lines[n-1].text) == ""; n-- {
+				out.lines = out.lines[:n-1]
+			}
+		}
+	}
+
+	return out
+}
+
+// inOrder returns which of the sibling todos keep their original line:
+// the todos whose lines still follow each other once the others are left
+// out, picked to rewrite as few lines as possible when todos were moved.
+func (d *document) inOrder(todos []models.Todo, anchor int) []bool {
+	// weight is the number of lines kept by keeping the todo and the
+	// todos before it
+	weight := make([]int, len(todos))
+	prev := make([]int, len(todos))
+	best := -1
+	for i := range todos {
+		prev[i] = -1
+		line := todos[i].LineNum
+		if !d.isTodoLine(line) || line <= anchor {
+			continue
+		}
+		_, lines := models.CountTodos(todos[i : i+1])
+		weight[i] = lines
+		for j := 0; j < i; j++ {
+			if weight[j] > 0 && todos[j].LineNum < line && weight[j]+lines > weight[i] {
+				weight[i] = weight[j] + lines
+				prev[i] = j
+			}
+		}
+		if best < 0 || weight[i] >= weight[best] {
+			best = i
+		}
+	}
+
+	keep := make([]bool, len(todos))
+	for i := best; i >= 0; i = prev[i] {
+		keep[i] = true
+	}
+	return keep
+}
+
+// newArchiveSection returns an empty archive section to append to d.
+func (d *document) newArchiveSection() *document {
+	archive := &document{indent: d.indent, crlf: d.crlf, trailingNewline: true, section: true}
+	if n := len(d.lines); n > 0 && d.lines[n-1].text != "" {
+		archive.lines = append(archive.lines, docLine{})
+	}
+	archive.lines = append(archive.lines, docLine{text: archiveHeading})
+	return archive
+}
+
+// emptySection reports whether d is an archive section holding nothing but
+// its heading and blank lines.
+func (d *document) emptySection() bool {
+	for _, line := range d.lines {
+		if strings.TrimSpace(line.text) != "" && !archiveRegex.MatchString(line.text) {
+			return false
+		}
+	}
+	return true
+}
+
+// newArchiveFile returns an empty archive file for d.
+func (d *document) newArchiveFile() *document {
+	return &document{indent: d.indent, crlf: d.crlf, trailingNewline: true, archiveFile: true}
+}
+
+// String returns the file content of the document.
+func (d *document) String() string {
+	texts := make([]string, len(d.lines))
+	for i, line := range d.lines {
+		texts[i] = line.text
+	}
+
+	newline := "\n"
+	if d.crlf {
+		newline = "\r\n"
+	}
+
+	content := strings.Join(texts, newline)
+	if d.trailingNewline && len(texts) > 0 {
+		content += newline
+	}
+	if d.archive != nil && !d.archive.archiveFile {
+		content += d.archive.String()
+	}
+	return content
+}
+
+func (d *document) isTodoLine(lineNum int) bool {
+	return lineNum > 0 && lineNum <= len(d.lines) && d.lines[lineNum-1].kind == todoLine
+}
+
+func newTitleLine(name string) docLine {
+	return docLine{
+		text:  fmt.Sprintf("# %s", name),
+		kind:  titleLine,
+		title: name,
+	}
+}
+
+// indentWidth returns the width of the leading whitespace of text, with
+// tabs counting as four columns.
+func indentWidth(text string) int {
+	width := 0
+	for _, r := range text {
+		switch r {
+		case ' ':
+			width++
+		case '\t':
+			width += 4
+		default:
+			return width
+		}
+	}
+	return width
+}
+
+// renderTodoLine keeps the original text of line when the todo it was
+// parsed from is unchanged and still at the same depth, and formats a
+// fresh line otherwise.
+func renderTodoLine(line docLine, todo *models.Todo, depth int, indent string) docLine {
+	if line.kind == todoLine && line.depth == depth && sameTodo(&line.todo, todo) {
+		return line
+	}
+
+	checkbox := " "
+	if todo.Completed {
+		checkbox = "x"
+	}
+
+	stored := *todo
+	stored.Children = nil
+
+	return docLine{
+		text:   fmt.Sprintf("%s- [%s] %s", indent, checkbox, FormatTodoText(todo)),
+		kind:   todoLine,
+		todo:   stored,
+		indent: indent,
+		depth:  depth,
+	}
+}
+
+// renderNotes keeps the original note lines of line when the notes of the
+// todo are unchanged and the todo is still at the same indentation, and
+// writes the notes one level deeper than the todo otherwise.
+func renderNotes(line docLine, todo *models.Todo, indent, unit string) []string {
+	if line.kind == todoLine && line.indent == indent && line.todo.Notes == todo.Notes {
+		return line.notes
+	}
+	if todo.Notes == "" {
+		return nil
+	}
+
+	var notes []string
+	for _, note := range strings.Split(todo.Notes, "\n") {
+		if strings.TrimSpace(note) == "" {
+			notes = append(notes, "")
+		} else {
+			notes = append(notes, indent+unit+escapeNote(note))
+		}
+	}
+	return notes
+}
+
+// escapeNote writes a backslash before the dash of a note line that would
+// otherwise be read as a todo.
+func escapeNote(note string) string {
+	return checkboxNoteRegex.ReplaceAllString(note, `$1\$2`)
+}
+
+// unescapeNote removes the backslash written by escapeNote.
+func unescapeNote(note string) string {
+	if matches := checkboxNoteRegex.FindStringSubmatchIndex(note); matches != nil && strings.HasPrefix(note[matches[4]:], `\`) {
+		return note[:matches[4]] + note[matches[4]+1:]
+	}
+	return note
+}
@@ -0,0 +1,200 @@
+package storage
+
+import (
+	"testing"
+
+	"donut/models"
+)
+
+func newTodo(title string) models.Todo {
+	return models.Todo{Title: title, LineNum: -1}
+}
+
+func TestRenderRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"title only", "# Work\n"},
+		{"todos", "# Work\n\n- [ ] a ^aaaaaa\n- [x] b ✅ 2026-10-16 ^bbbbbb\n"},
+		{"subtasks", "# Work\n\n- [ ] a\n  - [ ] a1\n    - [x] a11\n  - [ ] a2\n- [ ] b\n"},
+		{"tab indents", "# Work\n\n- [ ] a\n\t- [ ] a1\n"},
+		{"prose and headings", "# Work\n\nSome intro.\n\n## Today\n\n- [ ] a\n\nSee [[home]].\n\n## Later\n- [ ] b\n"},
+		{"notes", "# Work\n\n- [ ] a\n  First line\n\n    indented\n  - [ ] a1\n    sub note\n- [ ] b\n"},
+		{"escaped note", "# Work\n\n- [ ] a\n  \\- [ ] not a todo\n"},
+		{"crlf", "# Work\r\n\r\n- [ ] a\r\n  - [ ] a1\r\n"},
+		{"no trailing newline", "# Work\n\n- [ ] a"},
+		{"archive section", "# Work\n\n- [ ] a\n\n## Archive\n\n- [x] b ✅ 2026-10-16\n"},
+		{"odd spacing", "# Work\n\n-  [ ]   a   \n* not a todo\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := parseDocument(tt.content)
+			project := doc.project("work.md")
+			if got := doc.render(&project).String(); got != tt.content {
+				t.Errorf("render = %q, want %q", got, tt.content)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		change  func(p *models.Project)
+		want    string
+	}{
+		{
+			name:    "edit keeps the other lines",
+			content: "# Work\n\nIntro\n\n- [ ]  a\n- [ ] b\n",
+			change:  func(p *models.Project) { p.Todos[1].Title = "B" },
+			want:    "# Work\n\nIntro\n\n- [ ]  a\n- [ ] B\n",
+		},
+		{
+			name:    "reorder",
+			content: "# Work\n\n- [ ] a\n- [ ] b\n- [ ] c\n",
+			change: func(p *models.Project) {
+				p.Todos[0], p.Todos[2] = p.Todos[2], p.Todos[0]
+			},
+			want: "# Work\n\n- [ ] c\n- [ ] b\n- [ ] a\n",
+		},
+		{
+			name:    "reorder moves subtasks and notes along",
+			content: "# Work\n\n- [ ] a\n  note\n  - [ ] a1\n- [ ] b\n",
+			change: func(p *models.Project) {
+				p.Todos[0], p.Todos[1] = p.Todos[1], p.Todos[0]
+			},
+			want: "# Work\n\n- [ ] b\n- [ ] a\n  note\n  - [ ] a1\n",
+		},
+		{
+			name:    "insert after a subtree",
+			content: "# Work\n\n- [ ] a\n  - [ ] a1\n    - [ ] a11\n\nOutro\n",
+			change: func(p *models.Project) {
+				p.Todos = append(p.Todos, newTodo("b"))
+			},
+			want: "# Work\n\n- [ ] a\n  - [ ] a1\n    - [ ] a11\n- [ ] b\n\nOutro\n",
+		},
+		{
+			name:    "insert after notes",
+			content: "# Work\n\n- [ ] a\n  note\n- [ ] c\n",
+			change: func(p *models.Project) {
+				p.Todos = append(p.Todos[:1], append([]models.Todo{newTodo("b")}, p.Todos[1:]...)...)
+			},
+			want: "# Work\n\n- [ ] a\n  note\n- [ ] b\n- [ ] c\n",
+		},
+		{
+			name:    "insert first",
+			content: "# Work\n\nIntro\n\n- [ ] b\n",
+			change: func(p *models.Project) {
+				p.Todos = append([]models.Todo{newTodo("a")}, p.Todos...)
+			},
+			want: "# Work\n\nIntro\n\n- [ ] a\n- [ ] b\n",
+		},
+		{
+			name:    "insert subtask takes the file indentation",
+			content: "# Work\n\n- [ ] a\n\t- [ ] a1\n- [ ] b\n",
+			change: func(p *models.Project) {
+				p.Todos[1].Children = append(p.Todos[1].Children, newTodo("b1"))
+			},
+			want: "# Work\n\n- [ ] a\n\t- [ ] a1\n- [ ] b\n\t- [ ] b1\n",
+		},
+		{
+			name:    "insert into an empty file",
+			content: "",
+			change: func(p *models.Project) {
+				p.Name = "Work"
+				p.Todos = append(p.Todos, newTodo("a"))
+			},
+			want: "# Work\n\n- [ ] a\n",
+		},
+		{
+			name:    "delete removes subtasks and notes",
+			content: "# Work\n\n- [ ] a\n  note\n  - [ ] a1\n- [ ] b\n\nOutro\n",
+			change:  func(p *models.Project) { p.Todos = p.Todos[1:] },
+			want:    "# Work\n\n- [ ] b\n\nOutro\n",
+		},
+		{
+			name:    "notes",
+			content: "# Work\n\n- [ ] a\n  old\n- [ ] b\n",
+			change: func(p *models.Project) {
+				p.Todos[0].SetNotes("new\n\n  - item")
+				p.Todos[1].SetNotes("Steps:\n- [ ] call Bob")
+			},
+			want: "# Work\n\n- [ ] a\n  new\n\n    - item\n- [ ] b\n  Steps:\n  \\- [ ] call Bob\n",
+		},
+		{
+			name:    "crlf",
+			content: "# Work\r\n\r\n- [ ] a\r\n",
+			change: func(p *models.Project) {
+				p.Todos[0].Completed = true
+				p.Todos = append(p.Todos, newTodo("b"))
+			},
+			want: "# Work\r\n\r\n- [x] a\r\n- [ ] b\r\n",
+		},
+		{
+			name:    "no trailing newline",
+			content: "# Work\n\n- [ ] a",
+			change: func(p *models.Project) {
+				p.Todos = append(p.Todos, newTodo("b"))
+			},
+			want: "# Work\n\n- [ ] a\n- [ ] b",
+		},
+		{
+			name:    "rename",
+			content: "# Work\n\n- [ ] a\n",
+			change:  func(p *models.Project) { p.Name = "Job" },
+			want:    "# Job\n\n- [ ] a\n",
+		},
+		{
+			name:    "archive",
+			content: "# Work\n\n- [x] a\n- [ ] b\n",
+			change: func(p *models.Project) {
+				p.Archive = p.Todos[:1]
+				p.Todos = p.Todos[1:]
+			},
+			want: "# Work\n\n- [ ] b\n\n## Archive\n\n- [x] a\n",
+		},
+		{
+			name:    "unarchive the last todo",
+			content: "# Work\n\n- [ ] b\n\n## Archive\n\n- [x] a\n",
+			change: func(p *models.Project) {
+				p.Todos = append(p.Todos, p.Archive...)
+				p.Archive = nil
+			},
+			want: "# Work\n\n- [ ] b\n- [x] a\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := parseDocument(tt.content)
+			project := doc.project("work.md")
+			tt.change(&project)
+
+			out := doc.render(&project)
+			if got := out.String(); got != tt.want {
+				t.Fatalf("render = %q, want %q", got, tt.want)
+			}
+
+			// The line numbers given to the todos point to their lines
+			var check func(todos []models.Todo)
+			check = func(todos []models.Todo) {
+				for i := range todos {
+					todo := &todos[i]
+					if !out.isTodoLine(todo.LineNum) || out.lines[todo.LineNum-1].todo.Title != todo.Title {
+						t.Errorf("todo %q has line %d", todo.Title, todo.LineNum)
+					}
+					check(todo.Children)
+				}
+			}
+			check(project.Todos)
+
+			// Rendering the result again changes nothing
+			if again := out.render(&project).String(); again != tt.want {
+				t.Errorf("second render = %q, want %q", again, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"regexp"
+	"strings"
+)
+
+// wikiLinkRegex matches Obsidian wiki-links and embeds to a file, e.g.
+// "[[work]]", "[[work.md|tasks]]" or "[[work#^k3x9a2]]". The first group
+// is the linked file, the second the heading, block and alias that follow.
+var wikiLinkRegex = regexp.MustCompile(`\[\[([^\[\]|#^]+)([^\[\]]*)\]\]`)
+
+// replaceLinks points the wiki-links of text to the project file from, with
+// or without its extension and in any case, to the file to.
+func replaceLinks(text, from, to string) string {
+	from = strings.TrimSuffix(from, ".md")
+	to = strings.TrimSuffix(to, ".md")
+
+	return wikiLinkRegex.ReplaceAllStringFunc(text, func(link string) string {
+		match := wikiLinkRegex.FindStringSubmatch(link)
+		target, ext := strings.TrimSpace(match[1]), ""
+		if strings.HasSuffix(strings.ToLower(target), ".md") {
+			target, ext = target[:len(target)-3], target[len(target)-3:]
+		}
+		if !strings.EqualFold(target, from) {
+			return link
+		}
+		return "[[" + to + ext + match[2] + "]]"
+	})
+}
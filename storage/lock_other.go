@@ -0,0 +1,9 @@
+//go:build !unix
+
+package storage
+
+// lockFile does nothing where flock is not available; saves still detect
+// files changed underneath them.
+func lockFile(path string) (func(), error) {
+	return func() {}, nil
+}
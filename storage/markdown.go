@@ -0,0 +1,559 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"donut/models"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Markdown stores each project as a markdown file in a directory.
+type Markdown struct {
+	donutDir string
+	// ArchiveToFile keeps the archived todos of projects that have none
+	// yet in a <project>.archive.md file instead of an archive section
+	ArchiveToFile bool
+
+	mu   sync.Mutex
+	docs map[string]*document
+	// deleted keeps the documents of deleted projects, so that saving
+	// one again, e.g. to undo the deletion, restores all its content
+	deleted map[string]*document
+	watcher *fsnotify.Watcher
+	stop    chan struct{}
+}
+
+func NewMarkdown(donutDir string) (*Markdown, error) {
+	if err := os.MkdirAll(donutDir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &Markdown{
+		donutDir: donutDir,
+		docs:     make(map[string]*document),
+		deleted:  make(map[string]*document),
+	}, nil
+}
+
+func (s *Markdown) Load() (*models.AppData, error) {
+	data := models.NewAppData()
+
+	files, err := os.ReadDir(s.donutDir)
+	if err != nil {
+		return &data, nil
+	}
+
+	for _, file := range files {
+		if !file.IsDir() && isTempFile(file.Name()) {
+			data.Warnings = append(data.Warnings, fmt.Sprintf(
+				"found %s left by an interrupted save; the project file was kept, remove it once checked",
+				filepath.Join(s.donutDir, file.Name())))
+			continue
+		}
+		if !file.IsDir() && strings.HasSuffix(file.Name(), ".md") && !isArchiveFile(file.Name()) {
+			project, err := s.loadProject(file.Name())
+			if err != nil {
+				continue
+			}
+			data.Projects = append(data.Projects, project)
+		}
+	}
+
+	// Projects missing from the order file, e.g. created by another
+	// program, come last in filename order
+	if content, err := os.ReadFile(s.orderPath()); err == nil {
+		positions := make(map[string]int)
+		for i, filename := range strings.Fields(string(content)) {
+			positions[filename] = i + 1
+		}
+		sort.SliceStable(data.Projects, func(i, j int) bool {
+			a, b := positions[data.Projects[i].Filename], positions[data.Projects[j].Filename]
+			return a != 0 && (b == 0 || a < b)
+		})
+	}
+
+	return &data, nil
+}
+
+func (s *Markdown) LoadProject(filename string) (models.Project, error) {
+	project, err := s.loadProject(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		s.mu.Lock()
+		delete(s.docs, filename)
+		s.mu.Unlock()
+	}
+	return project, err
+}
+
+func (s *Markdown) loadProject(filename string) (models.Project, error) {
+	filePath := filepath.Join(s.donutDir, filename)
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return models.Project{Filename: filename, Todos: []models.Todo{}}, err
+	}
+
+	doc := parseDocument(string(content))
+	if doc.archive == nil {
+		archive, err := os.ReadFile(filepath.Join(s.donutDir, archiveFilename(filename)))
+		if err == nil {
+			doc.archive = parseArchiveFile(string(archive))
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return models.Project{Filename: filename, Todos: []models.Todo{}}, err
+		}
+	}
+
+	s.mu.Lock()
+	s.docs[filename] = doc
+	s.mu.Unlock()
+
+	project := doc.project(filename)
+	if project.Name == "" {
+		baseName := strings.TrimSuffix(filename, ".md")
+		project.Name = strings.ReplaceAll(baseName, "-", " ")
+		project.Name = strings.Title(project.Name)
+	}
+
+	return project, nil
+}
+
+func (s *Markdown) Save(data *models.AppData) error {
+	for i := range data.Projects {
+		if !data.Projects[i].Dirty {
+			continue
+		}
+		if err := s.SaveProject(&data.Projects[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveProject writes the project. When the file changed since it was last
+// loaded or saved, both versions are merged and project is updated to the
+// merged result, or a *ConflictError is returned if they cannot be merged.
+func (s *Markdown) SaveProject(project *models.Project) error {
+	return s.saveProject(project, false)
+}
+
+func (s *Markdown) OverwriteProject(project *models.Project) error {
+	return s.saveProject(project, true)
+}
+
+// SaveProjects saves projects as one operation: all of them are merged
+// with the changes of other programs before any is written, so that a
+// conflict leaves every file untouched. Files are written in the order
+// given, so a todo moved to the first project is never missing from both
+// if donut is interrupted.
+func (s *Markdown) SaveProjects(projects ...*models.Project) error {
+	return s.saveProjects(projects, false)
+}
+
+func (s *Markdown) saveProject(project *models.Project, overwrite bool) error {
+	return s.saveProjects([]*models.Project{project}, overwrite)
+}
+
+// pendingSave is a project rendered and merged, ready to be written.
+type pendingSave struct {
+	project  *models.Project
+	doc      *document
+	rendered *document
+	// result is the project as it will be saved, with the line numbers of
+	// the rendered document
+	result models.Project
+	merged bool
+}
+
+func (s *Markdown) saveProjects(projects []*models.Project, overwrite bool) error {
+	// Other donut processes wait until we are done comparing and writing
+	unlock, err := lockFile(s.lockPath())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending := make([]pendingSave, 0, len(projects))
+	for _, project := range projects {
+		save, err := s.prepareSave(project, overwrite)
+		if err != nil {
+			return err
+		}
+		pending = append(pending, save)
+	}
+
+	for _, save := range pending {
+		if err := s.writeSave(save); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prepareSave renders the project over the document it was loaded from and
+// merges it with the file when another program changed it. The project is
+// left untouched until the save is written.
+func (s *Markdown) prepareSave(project *models.Project, overwrite bool) (pendingSave, error) {
+	filePath := project.GetFilePath(s.donutDir)
+
+	doc, known := s.docs[project.Filename]
+	if !known {
+		doc = s.deleted[project.Filename]
+		if doc == nil {
+			doc = parseDocument("")
+		}
+	}
+	if doc.archive == nil && len(project.Archive) > 0 && s.ArchiveToFile {
+		withArchive := *doc
+		withArchive.archive = doc.newArchiveFile()
+		doc = &withArchive
+	}
+
+	save := pendingSave{project: project, doc: doc, result: project.Clone()}
+	save.rendered = doc.render(&save.result)
+	if overwrite {
+		return save, nil
+	}
+
+	content, err := os.ReadFile(filePath)
+	switch {
+	case err == nil && string(content) != doc.String():
+		if !known {
+			// Another program created a project with the same filename
+			return save, &ConflictError{Filename: project.Filename}
+		}
+		merged, ok := mergeLines(splitLines(doc.String()), splitLines(save.rendered.String()), splitLines(string(content)))
+		if !ok {
+			return save, &ConflictError{Filename: project.Filename}
+		}
+		archive := save.rendered.archive
+		save.rendered = parseDocument(strings.Join(merged, "\n"))
+		if archive != nil && archive.archiveFile {
+			save.rendered.archive = archive
+		}
+		save.result = save.rendered.project(project.Filename)
+		save.merged = true
+	case errors.Is(err, fs.ErrNotExist) && known:
+		// The project was deleted by another program
+		return save, &ConflictError{Filename: project.Filename}
+	case err != nil && !errors.Is(err, fs.ErrNotExist):
+		return save, err
+	}
+	return save, nil
+}
+
+func (s *Markdown) writeSave(save pendingSave) error {
+	project, rendered, doc := save.project, save.rendered, save.doc
+
+	// Archived todos are written first, so that a todo being archived is
+	// never missing from both files
+	if archive := rendered.archive; archive != nil && archive.archiveFile &&
+		(doc.archive == nil || archive.String() != doc.archive.String()) {
+		archivePath := filepath.Join(s.donutDir, archiveFilename(project.Filename))
+		if err := writeFileAtomic(archivePath, []byte(archive.String()), 0644); err != nil {
+			return err
+		}
+	}
+
+	if err := writeFileAtomic(project.GetFilePath(s.donutDir), []byte(rendered.String()), 0644); err != nil {
+		return err
+	}
+
+	if save.merged {
+		*project = save.result
+	} else {
+		copyLineNums(project.Todos, save.result.Todos)
+		copyLineNums(project.Archive, save.result.Archive)
+	}
+	s.docs[project.Filename] = rendered
+	delete(s.deleted, project.Filename)
+	project.Dirty = false
+	return nil
+}
+
+// copyLineNums sets the line numbers of todos to those of rendered, a copy
+// of todos with the lines they were written at.
+func copyLineNums(todos, rendered []models.Todo) {
+	for i := range todos {
+		todos[i].LineNum = rendered[i].LineNum
+		copyLineNums(todos[i].Children, rendered[i].Children)
+	}
+}
+
+// DeleteProject moves the project file to the trash.
+func (s *Markdown) DeleteProject(project *models.Project) error {
+	unlock, err := lockFile(s.lockPath())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := s.moveToTrash(project.Filename); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if doc, ok := s.docs[project.Filename]; ok {
+		s.deleted[project.Filename] = doc
+	}
+	delete(s.docs, project.Filename)
+	s.mu.Unlock()
+	return nil
+}
+
+// ProjectExists reports whether the project file, or an archive file
+// for it, exists.
+func (s *Markdown) ProjectExists(filename string) (bool, error) {
+	for _, name := range []string{filename, archiveFilename(filename)} {
+		_, err := os.Lstat(filepath.Join(s.donutDir, name))
+		if err == nil {
+			return true, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// RenameProject renames the project file and its archive file, then
+// rewrites the wiki-links to it in every markdown file of the directory.
+func (s *Markdown) RenameProject(project *models.Project, filename string) ([]string, error) {
+	if filename == project.Filename {
+		return nil, nil
+	}
+
+	unlock, err := lockFile(s.lockPath())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	from := project.Filename
+	paths := []string{from, archiveFilename(from)}
+	targets := []string{filename, archiveFilename(filename)}
+	if _, known := s.docs[filename]; known {
+		return nil, fmt.Errorf("%s: %w", filename, fs.ErrExist)
+	}
+	// Case-insensitive file systems find the new file when only the case
+	// of the name changes
+	if !strings.EqualFold(from, filename) {
+		for _, target := range targets {
+			if _, err := os.Lstat(filepath.Join(s.donutDir, target)); err == nil {
+				return nil, fmt.Errorf("%s: %w", target, fs.ErrExist)
+			}
+		}
+	}
+
+	for i := range paths {
+		err := os.Rename(filepath.Join(s.donutDir, paths[i]), filepath.Join(s.donutDir, targets[i]))
+		if i > 0 && errors.Is(err, fs.ErrNotExist) {
+			// The project has no archive file
+			continue
+		}
+		if err != nil {
+			if i > 0 {
+				os.Rename(filepath.Join(s.donutDir, targets[0]), filepath.Join(s.donutDir, paths[0]))
+			}
+			return nil, err
+		}
+	}
+
+	if doc, ok := s.docs[from]; ok {
+		s.docs[filename] = doc
+		delete(s.docs, from)
+	}
+	project.Filename = filename
+
+	if content, err := os.ReadFile(s.orderPath()); err == nil {
+		filenames := strings.Fields(string(content))
+		for i := range filenames {
+			if filenames[i] == from {
+				filenames[i] = filename
+			}
+		}
+		if err := s.SaveOrder(filenames); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.updateLinks(from, filename)
+}
+
+// updateLinks points the wiki-links to the project file from to the file
+// to, in every markdown file of the directory. The documents of the files
+// changed are left as they were, so that the projects are reloaded rather
+// than saved over the new links.
+func (s *Markdown) updateLinks(from, to string) ([]string, error) {
+	files, err := os.ReadDir(s.donutDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, ".md") {
+			continue
+		}
+
+		path := filepath.Join(s.donutDir, name)
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return changed, err
+		}
+		replaced := replaceLinks(string(content), from, to)
+		if replaced == string(content) {
+			continue
+		}
+		if err := writeFileAtomic(path, []byte(replaced), 0644); err != nil {
+			return changed, err
+		}
+
+		if isArchiveFile(name) {
+			name = strings.TrimSuffix(name, ".archive.md") + ".md"
+		}
+		if !slices.Contains(changed, name) {
+			changed = append(changed, name)
+		}
+	}
+	return changed, nil
+}
+
+// SaveOrder writes the filenames, one per line, to the order file.
+func (s *Markdown) SaveOrder(filenames []string) error {
+	return writeFileAtomic(s.orderPath(), []byte(strings.Join(filenames, "\n")+"\n"), 0644)
+}
+
+// Watch reports changes to the project files of the directory. Changes
+// leaving a file identical to what was last loaded or saved, such as our
+// own writes, are not reported.
+func (s *Markdown) Watch() (<-chan string, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Add(s.donutDir); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.watcher = watcher
+	s.stop = make(chan struct{})
+	stop := s.stop
+	s.mu.Unlock()
+
+	changes := make(chan string)
+	go func() {
+		defer close(changes)
+		for {
+			select {
+			case <-stop:
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				filename := filepath.Base(event.Name)
+				if isArchiveFile(filename) {
+					filename = strings.TrimSuffix(filename, ".archive.md") + ".md"
+				}
+				if !strings.HasSuffix(filename, ".md") || !s.changed(filename) {
+					continue
+				}
+				select {
+				case changes <- filename:
+				case <-stop:
+					return
+				}
+			case _, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+			}
+		}
+	}()
+
+	return changes, nil
+}
+
+// changed reports whether the file, or its archive file, differs from the
+// last version loaded or saved.
+func (s *Markdown) changed(filename string) bool {
+	content, err := os.ReadFile(filepath.Join(s.donutDir, filename))
+	archive, archiveErr := os.ReadFile(filepath.Join(s.donutDir, archiveFilename(filename)))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	doc, known := s.docs[filename]
+	if err != nil {
+		return known
+	}
+	if !known || doc.String() != string(content) {
+		return true
+	}
+	switch {
+	case doc.archive != nil && doc.archive.archiveFile:
+		return archiveErr != nil || doc.archive.String() != string(archive)
+	case doc.archive == nil:
+		return archiveErr == nil
+	}
+	return false
+}
+
+func (s *Markdown) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.watcher == nil {
+		return nil
+	}
+	close(s.stop)
+	err := s.watcher.Close()
+	s.watcher = nil
+	s.stop = nil
+	return err
+}
+
+// archiveFilename returns the name of the file holding the archived todos
+// of a project, when they are not kept in an archive section.
+func archiveFilename(filename string) string {
+	return strings.TrimSuffix(filename, ".md") + ".archive.md"
+}
+
+func isArchiveFile(filename string) bool {
+	return strings.HasSuffix(filename, ".archive.md")
+}
+
+// orderPath is the file listing the projects in the order they are shown.
+func (s *Markdown) orderPath() string {
+	return filepath.Join(s.donutDir, ".donut-order")
+}
+
+// lockPath is the file locked while a project is saved or deleted.
+func (s *Markdown) lockPath() string {
+	return filepath.Join(s.donutDir, ".donut.lock")
+}
+
+func splitLines(content string) []string {
+	return strings.Split(content, "\n")
+}
+
+func (s *Markdown) GetDonutDir() string {
+	return s.donutDir
+}
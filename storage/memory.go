@@ -0,0 +1,211 @@
+package storage
+
+import (
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"sync"
+
+	"donut/models"
+)
+
+// Memory keeps projects in memory only, for tests such as those of the
+// commands and for trying donut out without touching any file.
+type Memory struct {
+	mu       sync.Mutex
+	projects []models.Project
+	changes  chan string
+}
+
+// NewMemory returns a memory backend holding a copy of projects.
+func NewMemory(projects ...models.Project) *Memory {
+	m := &Memory{}
+	for i := range projects {
+		m.projects = append(m.projects, projects[i].Clone())
+	}
+	return m
+}
+
+func (m *Memory) Load() (*models.AppData, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data := models.NewAppData()
+	for i := range m.projects {
+		data.Projects = append(data.Projects, m.projects[i].Clone())
+	}
+	return &data, nil
+}
+
+func (m *Memory) LoadProject(filename string) (models.Project, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.projects {
+		if m.projects[i].Filename == filename {
+			return m.projects[i].Clone(), nil
+		}
+	}
+	return models.Project{}, fmt.Errorf("project %s: %w", filename, fs.ErrNotExist)
+}
+
+func (m *Memory) Save(data *models.AppData) error {
+	for i := range data.Projects {
+		if !data.Projects[i].Dirty {
+			continue
+		}
+		if err := m.SaveProject(&data.Projects[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Memory) SaveProject(project *models.Project) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.saveProject(project)
+	return nil
+}
+
+func (m *Memory) SaveProjects(projects ...*models.Project) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, project := range projects {
+		m.saveProject(project)
+	}
+	return nil
+}
+
+func (m *Memory) saveProject(project *models.Project) {
+	for i := range m.projects {
+		if m.projects[i].Filename == project.Filename {
+			m.projects[i] = project.Clone()
+			m.projects[i].Dirty = false
+			project.Dirty = false
+			return
+		}
+	}
+	m.projects = append(m.projects, project.Clone())
+	m.projects[len(m.projects)-1].Dirty = false
+	project.Dirty = false
+}
+
+func (m *Memory) OverwriteProject(project *models.Project) error {
+	return m.SaveProject(project)
+}
+
+func (m *Memory) DeleteProject(project *models.Project) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.projects {
+		if m.projects[i].Filename == project.Filename {
+			m.projects = append(m.projects[:i], m.projects[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (m *Memory) ProjectExists(filename string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.projects {
+		if strings.EqualFold(m.projects[i].Filename, filename) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *Memory) RenameProject(project *models.Project, filename string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if filename == project.Filename {
+		return nil, nil
+	}
+	for i := range m.projects {
+		if m.projects[i].Filename == filename {
+			return nil, fmt.Errorf("%s: %w", filename, fs.ErrExist)
+		}
+	}
+
+	from := project.Filename
+	var changed []string
+	for i := range m.projects {
+		stored := &m.projects[i]
+		if stored.Filename == from {
+			stored.Filename = filename
+		}
+		todos := replaceTodoLinks(stored.Todos, from, filename)
+		if archive := replaceTodoLinks(stored.Archive, from, filename); todos || archive {
+			changed = append(changed, stored.Filename)
+		}
+	}
+	project.Filename = filename
+	return changed, nil
+}
+
+// replaceTodoLinks points the wiki-links in the titles and notes of todos
+// to the project file from to the file to, reporting whether any changed.
+func replaceTodoLinks(todos []models.Todo, from, to string) bool {
+	changed := false
+	for i := range todos {
+		if title := replaceLinks(todos[i].Title, from, to); title != todos[i].Title {
+			todos[i].Title = title
+			changed = true
+		}
+		if notes := replaceLinks(todos[i].Notes, from, to); notes != todos[i].Notes {
+			todos[i].Notes = notes
+			changed = true
+		}
+		if replaceTodoLinks(todos[i].Children, from, to) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+func (m *Memory) SaveOrder(filenames []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	positions := make(map[string]int)
+	for i, filename := range filenames {
+		positions[filename] = i + 1
+	}
+	sort.SliceStable(m.projects, func(i, j int) bool {
+		a, b := positions[m.projects[i].Filename], positions[m.projects[j].Filename]
+		return a != 0 && (b == 0 || a < b)
+	})
+	return nil
+}
+
+// Watch returns a channel that never receives anything, as projects can
+// only be changed through the backend itself.
+func (m *Memory) Watch() (<-chan string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.changes == nil {
+		m.changes = make(chan string)
+	}
+	return m.changes, nil
+}
+
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.changes != nil {
+		close(m.changes)
+		m.changes = nil
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package storage
+
+import "slices"
+
+// hunk replaces the base lines from start to end with lines.
+type hunk struct {
+	start, end int
+	lines      []string
+}
+
+func (h hunk) insertion() bool {
+	return h.start == h.end
+}
+
+// before reports whether h applies entirely before other.
+func (h hunk) before(other hunk) bool {
+	if h.insertion() {
+		return h.start <= other.start
+	}
+	return h.end <= other.start
+}
+
+// mergeLines merges the changes made from base to ours with those made
+// from base to theirs, in the manner of diff3. Changes to different lines
+// are all applied, and lines inserted at the same place on both sides are
+// all kept, ours first. ok is false when both sides changed the same
+// lines differently.
+func mergeLines(base, ours, theirs []string) (merged []string, ok bool) {
+	oursHunks := diffLines(base, ours)
+	theirsHunks := diffLines(base, theirs)
+
+	pos := 0
+	for len(oursHunks) > 0 || len(theirsHunks) > 0 {
+		var next hunk
+		switch {
+		case len(theirsHunks) == 0:
+			next, oursHunks = oursHunks[0], oursHunks[1:]
+		case len(oursHunks) == 0:
+			next, theirsHunks = theirsHunks[0], theirsHunks[1:]
+		default:
+			o, t := oursHunks[0], theirsHunks[0]
+			switch {
+			case o.start == t.start && o.end == t.end && slices.Equal(o.lines, t.lines):
+				next = o
+				oursHunks, theirsHunks = oursHunks[1:], theirsHunks[1:]
+			case o.insertion() && t.insertion() && o.start == t.start:
+				next = hunk{start: o.start, end: o.end, lines: append(slices.Clone(o.lines), t.lines...)}
+				oursHunks, theirsHunks = oursHunks[1:], theirsHunks[1:]
+			case o.before(t):
+				next, oursHunks = o, oursHunks[1:]
+			case t.before(o):
+				next, theirsHunks = t, theirsHunks[1:]
+			default:
+				return nil, false
+			}
+		}
+
+		merged = append(merged, base[pos:next.start]...)
+		merged = append(merged, next.lines...)
+		pos = next.end
+	}
+	return append(merged, base[pos:]...), true
+}
+
+// diffLines returns the hunks turning base into other.
+func diffLines(base, other []string) []hunk {
+	match := matchLines(base, other)
+
+	var hunks []hunk
+	for i, j := 0, 0; i < len(base) || j < len(other); {
+		if i < len(base) && match[i] == j {
+			i++
+			j++
+			continue
+		}
+
+		start := i
+		for i < len(base) && match[i] < 0 {
+			i++
+		}
+		end := len(other)
+		if i < len(base) {
+			end = match[i]
+		}
+		hunks = append(hunks, hunk{start: start, end: i, lines: other[j:end]})
+		j = end
+	}
+	return hunks
+}
+
+// matchLines returns, for each line of a, the index of the same line in b
+// along a longest common subsequence, or -1 when the line was removed.
+func matchLines(a, b []string) []int {
+	match := make([]int, len(a))
+	for i := range match {
+		match[i] = -1
+	}
+
+	// Only the lines between the common prefix and suffix need the
+	// quadratic search
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		match[prefix] = prefix
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		match[len(a)-1-suffix] = len(b) - 1 - suffix
+		suffix++
+	}
+
+	midA := a[prefix : len(a)-suffix]
+	midB := b[prefix : len(b)-suffix]
+
+	// lengths[x][y] is the length of the longest common subsequence of
+	// midA[x:] and midB[y:]
+	lengths := make([][]int, len(midA)+1)
+	for x := range lengths {
+		lengths[x] = make([]int, len(midB)+1)
+	}
+	for x := len(midA) - 1; x >= 0; x-- {
+		for y := len(midB) - 1; y >= 0; y-- {
+			if midA[x] == midB[y] {
+				lengths[x][y] = lengths[x+1][y+1] + 1
+			} else {
+				lengths[x][y] = max(lengths[x+1][y], lengths[x][y+1])
+			}
+		}
+	}
+
+	for x, y := 0, 0; x < len(midA) && y < len(midB); {
+		switch {
+		case midA[x] == midB[y]:
+			match[prefix+x] = prefix + y
+			x++
+			y++
+		case lengths[x+1][y] >= lengths[x][y+1]:
+			x++
+		default:
+			y++
+		}
+	}
+	return match
+}
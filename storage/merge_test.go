@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMergeLines(t *testing.T) {
+	tests := []struct {
+		name               string
+		base, ours, theirs string
+		want               string
+		conflict           bool
+	}{
+		{
+			name:   "unchanged",
+			base:   "a b c",
+			ours:   "a b c",
+			theirs: "a b c",
+			want:   "a b c",
+		},
+		{
+			name:   "one side changed",
+			base:   "a b c",
+			ours:   "a B c",
+			theirs: "a b c",
+			want:   "a B c",
+		},
+		{
+			name:   "disjoint edits",
+			base:   "a b c d e",
+			ours:   "A b c d e",
+			theirs: "a b c d E",
+			want:   "A b c d E",
+		},
+		{
+			name:   "adjacent edits",
+			base:   "a b c d",
+			ours:   "a B c d",
+			theirs: "a b C d",
+			want:   "a B C d",
+		},
+		{
+			name:   "same edit on both sides",
+			base:   "a b c",
+			ours:   "a B c",
+			theirs: "a B c",
+			want:   "a B c",
+		},
+		{
+			name:     "same line changed differently",
+			base:     "a b c",
+			ours:     "a B c",
+			theirs:   "a X c",
+			conflict: true,
+		},
+		{
+			name:     "line changed on one side and deleted on the other",
+			base:     "a b c",
+			ours:     "a B c",
+			theirs:   "a c",
+			conflict: true,
+		},
+		{
+			name:   "insertions at the same point",
+			base:   "a b",
+			ours:   "a x b",
+			theirs: "a y b",
+			want:   "a x y b",
+		},
+		{
+			name:   "insertions at the end",
+			base:   "a b",
+			ours:   "a b x",
+			theirs: "a b y",
+			want:   "a b x y",
+		},
+		{
+			name:   "insertion next to a deletion",
+			base:   "a b c",
+			ours:   "a x b c",
+			theirs: "a c",
+			want:   "a x c",
+		},
+		{
+			name:   "deletions on both sides",
+			base:   "a b c d",
+			ours:   "a c d",
+			theirs: "a b c",
+			want:   "a c",
+		},
+		{
+			name:   "empty base",
+			base:   "",
+			ours:   "x",
+			theirs: "y",
+			want:   "x y",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, ok := mergeLines(strings.Fields(tt.base), strings.Fields(tt.ours), strings.Fields(tt.theirs))
+			if ok == tt.conflict {
+				t.Fatalf("ok = %v, want %v", ok, !tt.conflict)
+			}
+			if want := strings.Fields(tt.want); ok && !slices.Equal(merged, want) {
+				t.Errorf("merged = %q, want %q", merged, want)
+			}
+		})
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name        string
+		base, other string
+		want        []hunk
+	}{
+		{"unchanged", "a b c", "a b c", nil},
+		{"insertion", "a c", "a b c", []hunk{{start: 1, end: 1, lines: []string{"b"}}}},
+		{"deletion", "a b c", "a c", []hunk{{start: 1, end: 2, lines: []string{}}}},
+		{"change", "a b c", "a x c", []hunk{{start: 1, end: 2, lines: []string{"x"}}}},
+		{"move", "a b c", "b c a", []hunk{
+			{start: 0, end: 1, lines: []string{}},
+			{start: 3, end: 3, lines: []string{"a"}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hunks := diffLines(strings.Fields(tt.base), strings.Fields(tt.other))
+			if !slices.EqualFunc(hunks, tt.want, func(a, b hunk) bool {
+				return a.start == b.start && a.end == b.end && slices.Equal(a.lines, b.lines)
+			}) {
+				t.Errorf("hunks = %+v, want %+v", hunks, tt.want)
+			}
+		})
+	}
+}
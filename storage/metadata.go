@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"donut/models"
+)
+
+// Todo metadata is stored inline at the end of the todo line using the
+// Obsidian Tasks emoji format, e.g. "- [x] Write docs ⏫ ➕ 2026-10-17 ✅ 2026-10-18".
+// The todo ID comes last as an Obsidian block reference, e.g. "^k3x9a2".
+const (
+	createdMarker   = "➕"
+	scheduledMarker = "⏳"
+	dueMarker       = "📅"
+	completedMarker = "✅"
+
+	dateLayout = "2006-01-02"
+)
+
+var (
+	metadataRegex = regexp.MustCompile(`\s*(?:(➕|⏳|📅|✅)\s*(\d{4}-\d{2}-\d{2})|(🔺|⏫|🔼|🔽|⏬))\s*$`)
+	blockIDRegex  = regexp.MustCompile(`\s+\^([A-Za-z0-9-]+)\s*$`)
+
+	priorityMarkers = map[models.Priority]string{
+		models.PriorityHighest: "🔺",
+		models.PriorityHigh:    "⏫",
+		models.PriorityMedium:  "🔼",
+		models.PriorityLow:     "🔽",
+		models.PriorityLowest:  "⏬",
+	}
+)
+
+// parseTodoText splits the text following a checkbox into the todo title
+// and its trailing metadata.
+func parseTodoText(text string) models.Todo {
+	var todo models.Todo
+
+	if matches := blockIDRegex.FindStringSubmatchIndex(text); matches != nil && matches[0] > 0 {
+		todo.ID = text[matches[2]:matches[3]]
+		text = text[:matches[0]]
+	}
+
+	for {
+		matches := metadataRegex.FindStringSubmatchIndex(text)
+		if matches == nil || matches[0] == 0 {
+			break
+		}
+
+		if matches[6] >= 0 {
+			marker := text[matches[6]:matches[7]]
+			for priority, m := range priorityMarkers {
+				if m == marker {
+					todo.Priority = priority
+				}
+			}
+			text = text[:matches[0]]
+			continue
+		}
+
+		value := text[matches[4]:matches[5]]
+		date, err := time.ParseInLocation(dateLayout, value, time.Local)
+		if err != nil {
+			break
+		}
+
+		switch text[matches[2]:matches[3]] {
+		case createdMarker:
+			todo.CreatedAt = date
+		case scheduledMarker:
+			todo.Scheduled = date
+		case dueMarker:
+			todo.Due = date
+		case completedMarker:
+			todo.CompletedAt = date
+		}
+		text = text[:matches[0]]
+	}
+
+	todo.SetTitle(strings.TrimSpace(text))
+	return todo
+}
+
+// FormatTodoText returns the todo title followed by its metadata, as
+// written after the checkbox in project files.
+func FormatTodoText(todo *models.Todo) string {
+	var text strings.Builder
+	text.WriteString(todo.Title)
+
+	if marker, ok := priorityMarkers[todo.Priority]; ok {
+		text.WriteString(" " + marker)
+	}
+	if !todo.CreatedAt.IsZero() {
+		text.WriteString(" " + createdMarker + " " + todo.CreatedAt.Format(dateLayout))
+	}
+	if !todo.Scheduled.IsZero() {
+		text.WriteString(" " + scheduledMarker + " " + todo.Scheduled.Format(dateLayout))
+	}
+	if !todo.Due.IsZero() {
+		text.WriteString(" " + dueMarker + " " + todo.Due.Format(dateLayout))
+	}
+	if todo.Completed && !todo.CompletedAt.IsZero() {
+		text.WriteString(" " + completedMarker + " " + todo.CompletedAt.Format(dateLayout))
+	}
+	if todo.ID != "" {
+		text.WriteString(" ^" + todo.ID)
+	}
+
+	return text.String()
+}
+
+// sameTodo reports whether a and b would be written as the same line.
+func sameTodo(a, b *models.Todo) bool {
+	return a.Completed == b.Completed && FormatTodoText(a) == FormatTodoText(b)
+}
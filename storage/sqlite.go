@@ -0,0 +1,671 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"donut/models"
+
+	_ "modernc.org/sqlite"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS projects (
+	filename TEXT PRIMARY KEY,
+	name     TEXT NOT NULL,
+	position INTEGER NOT NULL,
+	revision INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS todos (
+	id           INTEGER PRIMARY KEY,
+	project      TEXT NOT NULL REFERENCES projects(filename) ON DELETE CASCADE ON UPDATE CASCADE,
+	parent       INTEGER REFERENCES todos(id) ON DELETE CASCADE,
+	position     INTEGER NOT NULL,
+	title        TEXT NOT NULL,
+	completed    INTEGER NOT NULL,
+	priority     INTEGER NOT NULL,
+	created_at   TEXT,
+	completed_at TEXT,
+	due          TEXT,
+	scheduled    TEXT,
+	archived     INTEGER NOT NULL DEFAULT 0,
+	uid          TEXT NOT NULL DEFAULT '',
+	notes        TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS todos_by_project ON todos(project, parent, position);
+CREATE INDEX IF NOT EXISTS todos_by_due ON todos(due) WHERE due IS NOT NULL;
+`
+
+// sqlitePollInterval is how often Watch checks the database for projects
+// saved by other processes.
+const sqlitePollInterval = time.Second
+
+// SQLite stores projects and todos in an SQLite database, which scales
+// better than markdown files to thousands of todos as saving a project
+// only touches its own rows.
+type SQLite struct {
+	db *sql.DB
+
+	mu sync.Mutex
+	// revisions are the revisions of the projects as last loaded or saved,
+	// which saves check against
+	revisions map[string]int64
+	// watched are the latest revisions this process knows of, which Watch
+	// reports changes against
+	watched map[string]int64
+	stop    chan struct{}
+	changes chan string
+}
+
+func NewSQLite(path string) (*SQLite, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	dsn := "file:" + (&url.URL{Path: path}).EscapedPath() +
+		"?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)"
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("initializing %s: %w", path, err)
+	}
+	if err := migrateSQLite(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrating %s: %w", path, err)
+	}
+
+	return &SQLite{
+		db:        db,
+		revisions: make(map[string]int64),
+		watched:   make(map[string]int64),
+	}, nil
+}
+
+// sqliteAddedColumns are the columns of the todos table that databases
+// created by older versions lack.
+var sqliteAddedColumns = []struct{ name, definition string }{
+	{"archived", "INTEGER NOT NULL DEFAULT 0"},
+	{"uid", "TEXT NOT NULL DEFAULT ''"},
+	{"notes", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// migrateSQLite adds the columns missing from databases created by older
+// versions.
+func migrateSQLite(db *sql.DB) error {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info('todos')`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		columns[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, column := range sqliteAddedColumns {
+		if columns[column.name] {
+			continue
+		}
+		if _, err := db.Exec(`ALTER TABLE todos ADD COLUMN ` + column.name + ` ` + column.definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sqliteTodo is a row of the todos table.
+type sqliteTodo struct {
+	id       int64
+	parent   sql.NullInt64
+	archived bool
+	todo     models.Todo
+}
+
+func (s *SQLite) Load() (*models.AppData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data := models.NewAppData()
+
+	rows, err := s.db.Query(`SELECT filename, name, revision FROM projects ORDER BY position, name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	index := make(map[string]int)
+	revisions := make(map[string]int64)
+	for rows.Next() {
+		var project models.Project
+		var revision int64
+		if err := rows.Scan(&project.Filename, &project.Name, &revision); err != nil {
+			return nil, err
+		}
+		project.Todos = []models.Todo{}
+		index[project.Filename] = len(data.Projects)
+		revisions[project.Filename] = revision
+		data.Projects = append(data.Projects, project)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	children, err := s.queryTodos(`
+		SELECT id, project, parent, title, completed, priority, created_at, completed_at, due, scheduled, archived, uid, notes
+		FROM todos ORDER BY project, position`)
+	if err != nil {
+		return nil, err
+	}
+
+	for filename, rows := range children {
+		if i, ok := index[filename]; ok {
+			if todos := buildSQLiteTodos(rows, 0); todos != nil {
+				data.Projects[i].Todos = todos
+			}
+			data.Projects[i].Archive = buildSQLiteTodos(rows, sqliteArchived)
+		}
+	}
+
+	s.revisions = revisions
+	s.watched = maps.Clone(revisions)
+	return &data, nil
+}
+
+func (s *SQLite) LoadProject(filename string) (models.Project, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	project := models.Project{Todos: []models.Todo{}}
+	var revision int64
+	err := s.db.QueryRow(`SELECT filename, name, revision FROM projects WHERE filename = ?`, filename).
+		Scan(&project.Filename, &project.Name, &revision)
+	if errors.Is(err, sql.ErrNoRows) {
+		s.forget(filename)
+		return models.Project{}, fmt.Errorf("project %s: %w", filename, fs.ErrNotExist)
+	}
+	if err != nil {
+		return models.Project{}, err
+	}
+
+	children, err := s.queryTodos(`
+		SELECT id, project, parent, title, completed, priority, created_at, completed_at, due, scheduled, archived, uid, notes
+		FROM todos WHERE project = ? ORDER BY position`, filename)
+	if err != nil {
+		return models.Project{}, err
+	}
+	if todos := buildSQLiteTodos(children[filename], 0); todos != nil {
+		project.Todos = todos
+	}
+	project.Archive = buildSQLiteTodos(children[filename], sqliteArchived)
+
+	s.remember(filename, revision)
+	return project, nil
+}
+
+// sqliteArchived is the parent id grouping archived top-level todos.
+const sqliteArchived = -1
+
+// queryTodos runs a query selecting todo rows and groups them by project
+// and parent id, 0 standing for top-level todos and sqliteArchived for
+// archived ones.
+func (s *SQLite) queryTodos(query string, args ...any) (map[string]map[int64][]sqliteTodo, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	children := make(map[string]map[int64][]sqliteTodo)
+	for rows.Next() {
+		var row sqliteTodo
+		var project, title, notes string
+		var createdAt, completedAt, due, scheduled sql.NullString
+		if err := rows.Scan(&row.id, &project, &row.parent, &title, &row.todo.Completed, &row.todo.Priority,
+			&createdAt, &completedAt, &due, &scheduled, &row.archived, &row.todo.ID, &notes); err != nil {
+			return nil, err
+		}
+
+		row.todo.SetTitle(title)
+		row.todo.Notes = notes
+		row.todo.LineNum = -1
+		row.todo.CreatedAt = parseSQLiteTime(createdAt)
+		row.todo.CompletedAt = parseSQLiteTime(completedAt)
+		row.todo.Due = parseSQLiteTime(due)
+		row.todo.Scheduled = parseSQLiteTime(scheduled)
+
+		if children[project] == nil {
+			children[project] = make(map[int64][]sqliteTodo)
+		}
+		parent := row.parent.Int64
+		if row.archived && !row.parent.Valid {
+			parent = sqliteArchived
+		}
+		children[project][parent] = append(children[project][parent], row)
+	}
+	return children, rows.Err()
+}
+
+// buildSQLiteTodos returns the tree of todos below parent.
+func buildSQLiteTodos(rows map[int64][]sqliteTodo, parent int64) []models.Todo {
+	var todos []models.Todo
+	for _, row := range rows[parent] {
+		todo := row.todo
+		todo.Children = buildSQLiteTodos(rows, row.id)
+		todos = append(todos, todo)
+	}
+	return todos
+}
+
+func (s *SQLite) Save(data *models.AppData) error {
+	for i := range data.Projects {
+		if !data.Projects[i].Dirty {
+			continue
+		}
+		if err := s.SaveProject(&data.Projects[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveProject replaces the stored todos of the project in a single
+// transaction.
+func (s *SQLite) SaveProject(project *models.Project) error {
+	return s.SaveProjects(project)
+}
+
+// SaveProjects saves projects in a single transaction. A *ConflictError is
+// returned, and nothing saved, when another program saved one of them
+// since it was last loaded or saved.
+func (s *SQLite) SaveProjects(projects ...*models.Project) error {
+	return s.saveProjects(false, projects...)
+}
+
+func (s *SQLite) saveProjects(overwrite bool, projects ...*models.Project) error {
+	// Holding the lock keeps Watch from reporting our own revision
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if !overwrite {
+		for _, project := range projects {
+			if err := s.checkRevision(tx, project.Filename); err != nil {
+				return err
+			}
+		}
+	}
+
+	revisions := make([]int64, len(projects))
+	for i, project := range projects {
+		if revisions[i], err = saveSQLiteProject(tx, project); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	for i, project := range projects {
+		s.remember(project.Filename, revisions[i])
+		project.Dirty = false
+	}
+	return nil
+}
+
+// checkRevision returns a *ConflictError when the stored revision of the
+// project is not the one last loaded or saved.
+func (s *SQLite) checkRevision(tx *sql.Tx, filename string) error {
+	var revision int64
+	err := tx.QueryRow(`SELECT revision FROM projects WHERE filename = ?`, filename).Scan(&revision)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Projects deleted by another program are saved again
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if known, ok := s.revisions[filename]; !ok || known != revision {
+		return &ConflictError{Filename: filename}
+	}
+	return nil
+}
+
+// saveSQLiteProject replaces the rows of the project and returns its new
+// revision.
+func saveSQLiteProject(tx *sql.Tx, project *models.Project) (int64, error) {
+	var revision int64
+	err := tx.QueryRow(`
+		INSERT INTO projects (filename, name, position)
+		VALUES (?, ?, (SELECT COALESCE(MAX(position), -1) + 1 FROM projects))
+		ON CONFLICT (filename) DO UPDATE SET name = excluded.name, revision = revision + 1
+		RETURNING revision`, project.Filename, project.Name).Scan(&revision)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM todos WHERE project = ?`, project.Filename); err != nil {
+		return 0, err
+	}
+
+	insert, err := tx.Prepare(`
+		INSERT INTO todos (project, parent, position, title, completed, priority, created_at, completed_at, due, scheduled, archived, uid, notes)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, err
+	}
+	defer insert.Close()
+
+	var insertTodos func(todos []models.Todo, parent sql.NullInt64, archived bool) error
+	insertTodos = func(todos []models.Todo, parent sql.NullInt64, archived bool) error {
+		for i := range todos {
+			todo := &todos[i]
+			result, err := insert.Exec(project.Filename, parent, i, todo.Title, todo.Completed, todo.Priority,
+				formatSQLiteTime(todo.CreatedAt), formatSQLiteTime(todo.CompletedAt),
+				formatSQLiteTime(todo.Due), formatSQLiteTime(todo.Scheduled), archived, todo.ID, todo.Notes)
+			if err != nil {
+				return err
+			}
+
+			id, err := result.LastInsertId()
+			if err != nil {
+				return err
+			}
+			if err := insertTodos(todo.Children, sql.NullInt64{Int64: id, Valid: true}, archived); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := insertTodos(project.Todos, sql.NullInt64{}, false); err != nil {
+		return 0, err
+	}
+	if err := insertTodos(project.Archive, sql.NullInt64{}, true); err != nil {
+		return 0, err
+	}
+	return revision, nil
+}
+
+// OverwriteProject saves the project whatever revision is stored.
+func (s *SQLite) OverwriteProject(project *models.Project) error {
+	return s.saveProjects(true, project)
+}
+
+func (s *SQLite) DeleteProject(project *models.Project) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.db.Exec(`DELETE FROM projects WHERE filename = ?`, project.Filename); err != nil {
+		return err
+	}
+
+	s.forget(project.Filename)
+	return nil
+}
+
+// remember records the revision of a project this process loaded or
+// saved.
+func (s *SQLite) remember(filename string, revision int64) {
+	s.revisions[filename] = revision
+	s.watched[filename] = revision
+}
+
+func (s *SQLite) forget(filename string) {
+	delete(s.revisions, filename)
+	delete(s.watched, filename)
+}
+
+func (s *SQLite) ProjectExists(filename string) (bool, error) {
+	var exists bool
+	err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM projects WHERE filename = ? COLLATE NOCASE)`, filename).Scan(&exists)
+	return exists, err
+}
+
+// RenameProject renames the project and rewrites the links in the titles
+// and notes of todos in a single transaction.
+func (s *SQLite) RenameProject(project *models.Project, filename string) ([]string, error) {
+	if filename == project.Filename {
+		return nil, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var taken bool
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM projects WHERE filename = ?)`, filename).Scan(&taken); err != nil {
+		return nil, err
+	}
+	if taken {
+		return nil, fmt.Errorf("%s: %w", filename, fs.ErrExist)
+	}
+
+	// The todos follow through ON UPDATE CASCADE
+	from := project.Filename
+	if _, err := tx.Exec(`UPDATE projects SET filename = ? WHERE filename = ?`, filename, from); err != nil {
+		return nil, err
+	}
+
+	type linkRow struct {
+		id      int64
+		project string
+		title   string
+		notes   string
+	}
+	rows, err := tx.Query(`SELECT id, project, title, notes FROM todos WHERE title LIKE '%[[%' OR notes LIKE '%[[%'`)
+	if err != nil {
+		return nil, err
+	}
+	var links []linkRow
+	for rows.Next() {
+		var row linkRow
+		if err := rows.Scan(&row.id, &row.project, &row.title, &row.notes); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		links = append(links, row)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for _, row := range links {
+		title := replaceLinks(row.title, from, filename)
+		notes := replaceLinks(row.notes, from, filename)
+		if title == row.title && notes == row.notes {
+			continue
+		}
+		if _, err := tx.Exec(`UPDATE todos SET title = ?, notes = ? WHERE id = ?`, title, notes, row.id); err != nil {
+			return nil, err
+		}
+		if !slices.Contains(changed, row.project) {
+			changed = append(changed, row.project)
+		}
+	}
+
+	revisions := make(map[string]int64)
+	for _, name := range append([]string{filename}, changed...) {
+		var revision int64
+		err := tx.QueryRow(`UPDATE projects SET revision = revision + 1 WHERE filename = ? RETURNING revision`, name).Scan(&revision)
+		if err != nil {
+			return nil, err
+		}
+		revisions[name] = revision
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	s.forget(from)
+	for name, revision := range revisions {
+		s.remember(name, revision)
+	}
+	project.Filename = filename
+	return changed, nil
+}
+
+// SaveOrder stores the position of every project in a single transaction.
+func (s *SQLite) SaveOrder(filenames []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i, filename := range filenames {
+		if _, err := tx.Exec(`UPDATE projects SET position = ? WHERE filename = ?`, i, filename); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Watch polls the database for projects saved or deleted by other
+// processes.
+func (s *SQLite) Watch() (<-chan string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.changes != nil {
+		return s.changes, nil
+	}
+	s.stop = make(chan struct{})
+	s.changes = make(chan string)
+
+	go func(stop chan struct{}, changes chan string) {
+		defer close(changes)
+		ticker := time.NewTicker(sqlitePollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+
+			for _, filename := range s.changedProjects() {
+				select {
+				case changes <- filename:
+				case <-stop:
+					return
+				}
+			}
+		}
+	}(s.stop, s.changes)
+
+	return s.changes, nil
+}
+
+// changedProjects returns the projects whose revision differs from the
+// latest one known, and records the new revisions. The revisions saves
+// check against stay those last loaded or saved, so that saving a project
+// changed by another process still reports a conflict until it is
+// reloaded.
+func (s *SQLite) changedProjects() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rows, err := s.db.Query(`SELECT filename, revision FROM projects`)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	current := make(map[string]int64)
+	for rows.Next() {
+		var filename string
+		var revision int64
+		if err := rows.Scan(&filename, &revision); err != nil {
+			return nil
+		}
+		current[filename] = revision
+	}
+	if rows.Err() != nil {
+		return nil
+	}
+
+	var changed []string
+	for filename, revision := range current {
+		if known, ok := s.watched[filename]; !ok || known != revision {
+			changed = append(changed, filename)
+		}
+	}
+	for filename := range s.watched {
+		if _, ok := current[filename]; !ok {
+			changed = append(changed, filename)
+		}
+	}
+	s.watched = current
+	return changed
+}
+
+func (s *SQLite) Close() error {
+	s.mu.Lock()
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+		s.changes = nil
+	}
+	s.mu.Unlock()
+
+	return s.db.Close()
+}
+
+func formatSQLiteTime(t time.Time) sql.NullString {
+	if t.IsZero() {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: t.Format(time.RFC3339Nano), Valid: true}
+}
+
+func parseSQLiteTime(value sql.NullString) time.Time {
+	if !value.Valid {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339Nano, value.String)
+	if err != nil {
+		return time.Time{}
+	}
+	return t.Local()
+}
@@ -0,0 +1,261 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"donut/models"
+)
+
+func openSQLite(t *testing.T, path string) *SQLite {
+	t.Helper()
+	s, err := NewSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSQLiteConcurrentSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "donut.db")
+	a := openSQLite(t, path)
+	b := openSQLite(t, path)
+
+	project := models.Project{Name: "Work", Filename: "work.md", Todos: []models.Todo{newTodo("a")}}
+	if err := a.SaveProject(&project); err != nil {
+		t.Fatal(err)
+	}
+
+	theirs, err := b.LoadProject("work.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	theirs.Todos[0].SetTitle("b")
+	if err := b.SaveProject(&theirs); err != nil {
+		t.Fatal(err)
+	}
+
+	// Noticing the change does not make it the revision saves are based on
+	if changed := a.changedProjects(); !slices.Equal(changed, []string{"work.md"}) {
+		t.Errorf("changed = %q, want work.md", changed)
+	}
+	if changed := a.changedProjects(); len(changed) != 0 {
+		t.Errorf("changed again = %q, want none", changed)
+	}
+
+	project.Todos[0].SetTitle("c")
+	var conflict *ConflictError
+	if err := a.SaveProject(&project); !errors.As(err, &conflict) {
+		t.Fatalf("save = %v, want a conflict", err)
+	}
+
+	// Once reloaded the project saves again
+	project, err = a.LoadProject("work.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.SaveProject(&project); err != nil {
+		t.Errorf("save after reload = %v", err)
+	}
+}
+
+// describeTodos lists the persisted fields of todos, one line per todo
+// indented by depth, so that projects from different backends compare.
+func describeTodos(todos []models.Todo, depth int, lines []string) []string {
+	date := func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.Format(dateLayout)
+	}
+	for _, todo := range todos {
+		lines = append(lines, fmt.Sprintf("%s%s %q done=%v priority=%d created=%s completed=%s due=%s scheduled=%s notes=%q",
+			strings.Repeat("  ", depth), todo.ID, todo.Title, todo.Completed, todo.Priority,
+			date(todo.CreatedAt), date(todo.CompletedAt), date(todo.Due), date(todo.Scheduled), todo.Notes))
+		lines = describeTodos(todo.Children, depth+1, lines)
+	}
+	return lines
+}
+
+func checkSameProject(t *testing.T, got, want models.Project) {
+	t.Helper()
+	if got.Name != want.Name || got.Filename != want.Filename {
+		t.Errorf("project = %s (%s), want %s (%s)", got.Name, got.Filename, want.Name, want.Filename)
+	}
+	if g, w := describeTodos(got.Todos, 0, nil), describeTodos(want.Todos, 0, nil); !slices.Equal(g, w) {
+		t.Errorf("todos =\n%s\nwant\n%s", strings.Join(g, "\n"), strings.Join(w, "\n"))
+	}
+	if g, w := describeTodos(got.Archive, 0, nil), describeTodos(want.Archive, 0, nil); !slices.Equal(g, w) {
+		t.Errorf("archive =\n%s\nwant\n%s", strings.Join(g, "\n"), strings.Join(w, "\n"))
+	}
+}
+
+// sqliteTestProject has a todo setting every persisted field.
+func sqliteTestProject() models.Project {
+	day := func(d int) time.Time { return time.Date(2026, 10, d, 0, 0, 0, 0, time.Local) }
+
+	full := newTodo("Review #backend")
+	full.ID = "abc123"
+	full.Priority = models.PriorityHigh
+	full.CreatedAt = day(1)
+	full.Due = day(20)
+	full.Scheduled = day(18)
+	full.Notes = "First line\n\n  indented"
+
+	sub := newTodo("Check the tests")
+	sub.ID = "def456"
+	sub.Completed = true
+	sub.CompletedAt = day(16)
+	full.Children = []models.Todo{sub, newTodo("Merge")}
+	full.Children[1].ID = "ghi789"
+
+	archived := newTodo("Old")
+	archived.ID = "jkl012"
+	archived.Completed = true
+	archived.CompletedAt = day(2)
+
+	return models.Project{
+		Name:     "Work",
+		Filename: "work.md",
+		Todos:    []models.Todo{full},
+		Archive:  []models.Todo{archived},
+		Dirty:    true,
+	}
+}
+
+func TestSQLiteRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "donut.db")
+	project := sqliteTestProject()
+	empty := models.Project{Name: "Empty", Filename: "empty.md", Todos: []models.Todo{}, Dirty: true}
+
+	data := &models.AppData{Projects: []models.Project{project, empty}}
+	if err := openSQLite(t, path).Save(data); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := openSQLite(t, path).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Projects) != 2 {
+		t.Fatalf("loaded %d projects, want 2", len(loaded.Projects))
+	}
+	checkSameProject(t, loaded.Projects[0], project)
+	checkSameProject(t, loaded.Projects[1], empty)
+	if loaded.Projects[1].Todos == nil {
+		t.Error("todos of an empty project are nil")
+	}
+}
+
+func TestSQLiteMigrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "donut.db")
+
+	// The schema of the first version, before archives, IDs and notes
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE projects (
+			filename TEXT PRIMARY KEY,
+			name     TEXT NOT NULL,
+			position INTEGER NOT NULL,
+			revision INTEGER NOT NULL DEFAULT 0
+		);
+		CREATE TABLE todos (
+			id           INTEGER PRIMARY KEY,
+			project      TEXT NOT NULL REFERENCES projects(filename) ON DELETE CASCADE ON UPDATE CASCADE,
+			parent       INTEGER REFERENCES todos(id) ON DELETE CASCADE,
+			position     INTEGER NOT NULL,
+			title        TEXT NOT NULL,
+			completed    INTEGER NOT NULL,
+			priority     INTEGER NOT NULL,
+			created_at   TEXT,
+			completed_at TEXT,
+			due          TEXT,
+			scheduled    TEXT
+		);
+		INSERT INTO projects (filename, name, position) VALUES ('work.md', 'Work', 0);
+		INSERT INTO todos (project, position, title, completed, priority) VALUES ('work.md', 0, 'Call Bob', 0, 0);`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := openSQLite(t, path)
+	loaded, err := s.LoadProject("work.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Todos) != 1 || loaded.Todos[0].Title != "Call Bob" || loaded.Todos[0].ID != "" || loaded.Archive != nil {
+		t.Fatalf("loaded = %+v, want the todo without an ID", loaded)
+	}
+
+	// The added columns are written like the others
+	project := sqliteTestProject()
+	if err := s.SaveProject(&project); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err = openSQLite(t, path).LoadProject("work.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSameProject(t, loaded, project)
+}
+
+func TestSQLiteCopy(t *testing.T) {
+	dir := t.TempDir()
+	content := "# Work\n\n" +
+		"- [ ] Review #backend ⏫ ➕ 2026-10-01 ⏳ 2026-10-18 📅 2026-10-20 ^abc123\n" +
+		"  First line\n\n    indented\n" +
+		"  - [x] Check the tests ✅ 2026-10-16 ^def456\n" +
+		"  - [ ] Merge ^ghi789\n" +
+		"\n## Archive\n\n" +
+		"- [x] Old ✅ 2026-10-02 ^jkl012\n"
+	if err := os.WriteFile(filepath.Join(dir, "work.md"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	markdown, err := NewMarkdown(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := markdown.LoadProject("work.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSameProject(t, want, sqliteTestProject())
+
+	s := openSQLite(t, filepath.Join(t.TempDir(), "donut.db"))
+	if n, err := Copy(s, markdown); err != nil || n != 1 {
+		t.Fatalf("import = %d, %v, want 1 project", n, err)
+	}
+	imported, err := s.LoadProject("work.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSameProject(t, imported, want)
+
+	exportDir := t.TempDir()
+	exported, err := NewMarkdown(exportDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := Copy(exported, s); err != nil || n != 1 {
+		t.Fatalf("export = %d, %v, want 1 project", n, err)
+	}
+	written, err := os.ReadFile(filepath.Join(exportDir, "work.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(written) != content {
+		t.Errorf("exported file = %q, want %q", written, content)
+	}
+}
@@ -1,134 +1,123 @@
 package storage
 
 import (
-	"bufio"
 	"fmt"
-	"os"
-	"path/filepath"
-	"regexp"
 	"strings"
 
 	"donut/config"
 	"donut/models"
 )
 
-type Storage struct {
-	donutDir string
+// Backend loads and persists projects.
+type Backend interface {
+	Load() (*models.AppData, error)
+	// LoadProject reloads a single project, e.g. after Watch reported a
+	// change. The error satisfies errors.Is(err, fs.ErrNotExist) when the
+	// project no longer exists.
+	LoadProject(filename string) (models.Project, error)
+	// Save writes the projects marked dirty.
+	Save(data *models.AppData) error
+	// SaveProject writes the project whether or not it is dirty, and
+	// clears its dirty flag on success.
+	SaveProject(project *models.Project) error
+	// OverwriteProject saves the project, discarding the changes made
+	// by other programs that SaveProject reported as a conflict.
+	OverwriteProject(project *models.Project) error
+	// SaveProjects saves several projects as one operation, e.g. after a
+	// todo moved from one to another: a conflict in any of them leaves
+	// all of them unsaved.
+	SaveProjects(projects ...*models.Project) error
+	DeleteProject(project *models.Project) error
+	// ProjectExists reports whether the filename is used by a project, or
+	// by any other file where projects are stored.
+	ProjectExists(filename string) (bool, error)
+	// RenameProject gives the project a new filename and points the
+	// wiki-links to it, in every project, to the new name. It returns the
+	// filenames of the projects whose links changed, which are to be
+	// reloaded. The error satisfies errors.Is(err, fs.ErrExist) when the
+	// filename is taken.
+	RenameProject(project *models.Project, filename string) ([]string, error)
+	// SaveOrder remembers the order of the projects, given by their
+	// filenames, for Load to return them in.
+	SaveOrder(filenames []string) error
+	// Watch returns a channel receiving the filename of every project
+	// changed by another program. The channel is closed by Close.
+	Watch() (<-chan string, error)
+	Close() error
 }
 
-func New() (*Storage, error) {
-	cfg, err := config.Load()
-	if err != nil {
-		return nil, err
-	}
-
-	if err := os.MkdirAll(cfg.DonutDir, 0755); err != nil {
-		return nil, err
-	}
-
-	return &Storage{
-		donutDir: cfg.DonutDir,
-	}, nil
+// ConflictError is returned by SaveProject when the project was changed by
+// another program in a way that cannot be merged with the changes saved.
+type ConflictError struct {
+	Filename string
 }
 
-func (s *Storage) Load() (*models.AppData, error) {
-	data := models.NewAppData()
-
-	files, err := os.ReadDir(s.donutDir)
-	if err != nil {
-		return &data, nil
-	}
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s was changed by another program and the changes conflict", e.Filename)
+}
 
-	for _, file := range files {
-		if !file.IsDir() && strings.HasSuffix(file.Name(), ".md") {
-			project, err := s.loadProject(file.Name())
-			if err != nil {
-				continue
+// ProjectFilename returns the filename for a new project named name, or
+// for project when renaming it, that no other project uses, neither in data
+// nor in the backend, where another program may have created one since
+// data was loaded.
+func ProjectFilename(backend Backend, cfg *config.Config, data *models.AppData, name string, project *models.Project) string {
+	return models.ProjectFilename(name, cfg.UnicodeFilenames, func(filename string) bool {
+		if project != nil && strings.EqualFold(filename, project.Filename) {
+			return false
+		}
+		for i := range data.Projects {
+			if strings.EqualFold(data.Projects[i].Filename, filename) {
+				return true
 			}
-			data.Projects = append(data.Projects, project)
 		}
-	}
-
-
-	return &data, nil
+		// Errors are left for the save to report
+		exists, _ := backend.ProjectExists(filename)
+		return exists
+	})
 }
 
-func (s *Storage) loadProject(filename string) (models.Project, error) {
-	project := models.Project{
-		Filename: filename,
-		Todos:    []models.Todo{},
-	}
-
-	filePath := filepath.Join(s.donutDir, filename)
-	file, err := os.Open(filePath)
-	if err != nil {
-		return project, err
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	lineNum := 0
-	titleRegex := regexp.MustCompile(`^#\s+(.+)$`)
-	todoRegex := regexp.MustCompile(`^-\s+\[([ x])\]\s+(.+)$`)
-
-	for scanner.Scan() {
-		lineNum++
-		line := scanner.Text()
-
-		if matches := titleRegex.FindStringSubmatch(line); matches != nil {
-			project.Name = matches[1]
-		} else if matches := todoRegex.FindStringSubmatch(line); matches != nil {
-			completed := matches[1] == "x"
-			title := matches[2]
-			todo := models.Todo{
-				Title:     title,
-				Completed: completed,
-				LineNum:   lineNum,
-			}
-			project.Todos = append(project.Todos, todo)
+// New returns the backend selected by the backend key of the config.
+func New(cfg *config.Config) (Backend, error) {
+	switch cfg.Backend {
+	case "", "markdown":
+		m, err := NewMarkdown(cfg.DonutDir)
+		if err != nil {
+			return nil, err
 		}
+		m.ArchiveToFile = cfg.ArchiveTo == "file"
+		return m, nil
+	case "memory":
+		return NewMemory(), nil
+	case "sqlite":
+		return NewSQLite(cfg.SQLitePath)
 	}
+	return nil, fmt.Errorf("unknown backend %q", cfg.Backend)
+}
 
-	if project.Name == "" {
-		baseName := strings.TrimSuffix(filename, ".md")
-		project.Name = strings.ReplaceAll(baseName, "-", " ")
-		project.Name = strings.Title(project.Name)
+// Copy saves every project of src into dst, e.g. to import a markdown
+// directory into a database or to export it back. It returns the number
+// of projects copied.
+func Copy(dst, src Backend) (int, error) {
+	data, err := src.Load()
+	if err != nil {
+		return 0, err
 	}
 
-	return project, scanner.Err()
-}
-
-func (s *Storage) Save(data *models.AppData) error {
 	for i := range data.Projects {
-		if err := s.saveProject(&data.Projects[i]); err != nil {
-			return err
+		project := &data.Projects[i]
+		// Line numbers only make sense in the backend they come from
+		resetLineNums(project.Todos)
+		resetLineNums(project.Archive)
+		if err := dst.SaveProject(project); err != nil {
+			return i, err
 		}
 	}
-	return nil
+	return len(data.Projects), nil
 }
 
-func (s *Storage) saveProject(project *models.Project) error {
-	filePath := project.GetFilePath(s.donutDir)
-
-	var content strings.Builder
-	content.WriteString(fmt.Sprintf("# %s\n\n", project.Name))
-
-	for _, todo := range project.Todos {
-		checkbox := " "
-		if todo.Completed {
-			checkbox = "x"
-		}
-		content.WriteString(fmt.Sprintf("- [%s] %s\n", checkbox, todo.Title))
+func resetLineNums(todos []models.Todo) {
+	for i := range todos {
+		todos[i].LineNum = -1
+		resetLineNums(todos[i].Children)
 	}
-
-	return os.WriteFile(filePath, []byte(content.String()), 0644)
-}
-
-func (s *Storage) DeleteProject(project *models.Project) error {
-	filePath := project.GetFilePath(s.donutDir)
-	return os.Remove(filePath)
 }
-
-func (s *Storage) GetDonutDir() string {
-	return s.donutDir
-}
\ No newline at end of file
@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"donut/models"
+)
+
+const (
+	// trashDir is the directory of the donut directory holding deleted
+	// projects.
+	trashDir = ".trash"
+
+	// trashTimeLayout prefixes the files in the trash with the time they
+	// were deleted at.
+	trashTimeLayout = "20060102T150405.000"
+)
+
+// TrashEntry is a deleted project kept in the trash.
+type TrashEntry struct {
+	// ID identifies the entry in the trash
+	ID        string
+	Name      string
+	Filename  string
+	DeletedAt time.Time
+}
+
+// Trash is implemented by backends keeping deleted projects around until
+// they are purged.
+type Trash interface {
+	// TrashEntries returns the deleted projects, most recently deleted
+	// first.
+	TrashEntries() ([]TrashEntry, error)
+	// RestoreTrashed moves a deleted project back and returns it.
+	RestoreTrashed(id string) (models.Project, error)
+	// PurgeTrashed deletes a project from the trash for good.
+	PurgeTrashed(id string) error
+}
+
+// PurgeExpired purges the projects deleted more than retentionDays ago
+// from the trash of the backend, if it has one. A retention of 0 keeps
+// deleted projects forever. It returns the number of projects purged.
+func PurgeExpired(backend Backend, retentionDays int, now time.Time) (int, error) {
+	trash, ok := backend.(Trash)
+	if !ok || retentionDays <= 0 {
+		return 0, nil
+	}
+
+	entries, err := trash.TrashEntries()
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := now.AddDate(0, 0, -retentionDays)
+	purged := 0
+	for _, entry := range entries {
+		if entry.DeletedAt.Before(cutoff) {
+			if err := trash.PurgeTrashed(entry.ID); err != nil {
+				return purged, err
+			}
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// moveToTrash moves a project file and its archive file, if any, into the
+// trash directory, prefixed with the current time.
+func (s *Markdown) moveToTrash(filename string) error {
+	dir := filepath.Join(s.donutDir, trashDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	id := time.Now().Format(trashTimeLayout) + "-" + filename
+	archive := archiveFilename(filename)
+	err := os.Rename(filepath.Join(s.donutDir, archive), filepath.Join(dir, archiveFilename(id)))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return os.Rename(filepath.Join(s.donutDir, filename), filepath.Join(dir, id))
+}
+
+func (s *Markdown) TrashEntries() ([]TrashEntry, error) {
+	files, err := os.ReadDir(filepath.Join(s.donutDir, trashDir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []TrashEntry
+	for _, file := range files {
+		entry, ok := parseTrashID(file.Name())
+		if file.IsDir() || !ok || isArchiveFile(entry.Filename) {
+			continue
+		}
+
+		content, err := os.ReadFile(filepath.Join(s.donutDir, trashDir, entry.ID))
+		if err != nil {
+			return nil, err
+		}
+		entry.Name = parseDocument(string(content)).project(entry.Filename).Name
+		if entry.Name == "" {
+			entry.Name = entry.Filename
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].DeletedAt.After(entries[j].DeletedAt)
+	})
+	return entries, nil
+}
+
+func (s *Markdown) RestoreTrashed(id string) (models.Project, error) {
+	entry, ok := parseTrashID(id)
+	if !ok || isArchiveFile(id) {
+		return models.Project{}, fmt.Errorf("%q is not in the trash", id)
+	}
+
+	unlock, err := lockFile(s.lockPath())
+	if err != nil {
+		return models.Project{}, err
+	}
+	defer unlock()
+
+	filePath := filepath.Join(s.donutDir, entry.Filename)
+	if _, err := os.Stat(filePath); err == nil {
+		return models.Project{}, fmt.Errorf("cannot restore %s: the project already exists", entry.Filename)
+	}
+	if err := os.Rename(filepath.Join(s.donutDir, trashDir, id), filePath); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return models.Project{}, fmt.Errorf("%q is not in the trash", id)
+		}
+		return models.Project{}, err
+	}
+	err = os.Rename(filepath.Join(s.donutDir, trashDir, archiveFilename(id)),
+		filepath.Join(s.donutDir, archiveFilename(entry.Filename)))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return models.Project{}, err
+	}
+
+	s.mu.Lock()
+	delete(s.deleted, entry.Filename)
+	s.mu.Unlock()
+	return s.loadProject(entry.Filename)
+}
+
+func (s *Markdown) PurgeTrashed(id string) error {
+	if _, ok := parseTrashID(id); !ok || isArchiveFile(id) {
+		return fmt.Errorf("%q is not in the trash", id)
+	}
+
+	unlock, err := lockFile(s.lockPath())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = os.Remove(filepath.Join(s.donutDir, trashDir, archiveFilename(id)))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return os.Remove(filepath.Join(s.donutDir, trashDir, id))
+}
+
+// parseTrashID splits the name of a file in the trash into the time the
+// project was deleted and its filename.
+func parseTrashID(id string) (TrashEntry, bool) {
+	stamp, filename, ok := strings.Cut(id, "-")
+	if !ok || !strings.HasSuffix(filename, ".md") || filepath.Base(id) != id {
+		return TrashEntry{}, false
+	}
+	deletedAt, err := time.ParseInLocation(trashTimeLayout, stamp, time.Local)
+	if err != nil {
+		return TrashEntry{}, false
+	}
+	return TrashEntry{ID: id, Filename: filename, DeletedAt: deletedAt}, true
+}
@@ -0,0 +1,124 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbletea"
+)
+
+// archiveTodos moves the completed todos of the current project to its
+// archive.
+func (m *Model) archiveTodos() {
+	currentProject := m.getCurrentProject()
+	if currentProject == nil {
+		return
+	}
+
+	archived := currentProject.ArchiveCompleted(time.Time{})
+	if archived == 0 {
+		m.message = "No completed todos to archive"
+		return
+	}
+	if rows := m.projectRows(currentProject); m.todoCursor >= len(rows) {
+		m.todoCursor = max(len(rows)-1, 0)
+	}
+	m.saveProject(currentProject, "archive todos")
+	if m.message == "" {
+		m.message = fmt.Sprintf("Archived %d todo(s)", archived)
+	}
+}
+
+func (m *Model) openArchive() {
+	if m.getCurrentProject() == nil {
+		return
+	}
+	m.archiveCursor = 0
+	m.mode = ArchiveView
+}
+
+// unarchiveTodo moves the archived todo under the cursor, or the todo its
+// subtask belongs to, back to the todos of the current project.
+func (m *Model) unarchiveTodo() {
+	currentProject := m.getCurrentProject()
+	rows := archiveRows(currentProject)
+	if m.archiveCursor >= len(rows) {
+		return
+	}
+
+	root := m.archiveCursor
+	for rows[root].depth > 0 {
+		root--
+	}
+	title := rows[root].todo.Title
+	currentProject.Unarchive(rows[root].index)
+
+	rows = archiveRows(currentProject)
+	m.archiveCursor = max(min(root, len(rows)-1), 0)
+	m.saveProject(currentProject, "unarchive todo")
+	if m.message == "" {
+		m.message = fmt.Sprintf("Moved %q back to the todos", title)
+	}
+}
+
+func (m Model) handleArchiveKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	currentProject := m.getCurrentProject()
+	if currentProject == nil {
+		m.mode = ProjectView
+		return m, nil
+	}
+
+	switch msg.String() {
+	case "ctrl+c", "q":
+		return m, tea.Quit
+	case "esc", "v":
+		m.mode = TodoView
+	case "up", "k":
+		if m.archiveCursor > 0 {
+			m.archiveCursor--
+		}
+	case "down", "j":
+		if m.archiveCursor < len(archiveRows(currentProject))-1 {
+			m.archiveCursor++
+		}
+	case "tab":
+		if row, ok := rowAt(archiveRows(currentProject), m.archiveCursor); ok && len(row.todo.Children) > 0 {
+			row.todo.Collapsed = !row.todo.Collapsed
+		}
+	case "enter", "r":
+		m.unarchiveTodo()
+	case "u":
+		m.undo()
+	case "ctrl+r":
+		m.redo()
+	}
+	return m, nil
+}
+
+func (m Model) renderArchiveView() string {
+	currentProject := m.getCurrentProject()
+	if currentProject == nil {
+		return "No project selected"
+	}
+
+	title := titleStyle.Render(currentProject.Name + " Archive")
+
+	var lines []string
+	for i, row := range archiveRows(currentProject) {
+		cursor := " "
+		if i == m.archiveCursor {
+			cursor = ">"
+		}
+		lines = append(lines, fmt.Sprintf("%s %s", cursor, renderTodoRow(row, i == m.archiveCursor)))
+	}
+
+	content := strings.Join(lines, "\n")
+	if len(lines) == 0 {
+		content = "Nothing archived yet. Press 'A' in the project to archive completed todos."
+	}
+
+	help := mutedStyle.Render("\n\nenter/r (unarchive), tab (fold), u (undo), esc (back)")
+
+	return title + "\n" + content + m.renderMessage() + help
+}
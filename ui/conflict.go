@@ -0,0 +1,73 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/charmbracelet/bubbletea"
+)
+
+// handleConflictKeys lets the user pick which version of a project to keep
+// after its changes conflicted with changes made by another program.
+func (m Model) handleConflictKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	index := m.projectIndex(m.conflict)
+	if index < 0 {
+		m.conflict = ""
+		return m, nil
+	}
+	project := &m.data.Projects[index]
+
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "m":
+		m.conflict = ""
+		var err error
+		m.replaceTodos(index, func() {
+			err = m.storage.OverwriteProject(project)
+		})
+		if err != nil {
+			m.message = fmt.Sprintf("Could not save %s: %v", project.Name, err)
+			return m, nil
+		}
+		// The changes that conflicted were not recorded yet
+		m.record("keep my version", project.Filename, index, project)
+	case "t":
+		m.conflict = ""
+		theirs, err := m.storage.LoadProject(project.Filename)
+		if errors.Is(err, fs.ErrNotExist) {
+			m.setSaved(project.Filename, nil)
+			m.forgetHistory(project.Filename)
+			m.removeProject(index)
+			return m, nil
+		}
+		if err != nil {
+			m.message = fmt.Sprintf("Could not reload %s: %v", project.Name, err)
+			return m, nil
+		}
+		theirs.EnsureIDs()
+		m.setSaved(theirs.Filename, &theirs)
+		m.forgetHistory(theirs.Filename)
+		m.replaceTodos(index, func() {
+			m.data.Projects[index] = theirs
+		})
+	case "esc":
+		m.conflict = ""
+		m.message = fmt.Sprintf("Your changes to %s are not saved yet", project.Name)
+	}
+	return m, nil
+}
+
+func (m Model) renderConflictView() string {
+	name := m.conflict
+	if index := m.projectIndex(m.conflict); index >= 0 {
+		name = m.data.Projects[index].Name
+	}
+
+	title := titleStyle.Render("Conflicting Changes")
+	warning := fmt.Sprintf("'%s' was changed by another program and the changes conflict with yours.", name)
+	options := "\nPress 'm' to keep your version, 't' to keep theirs, Esc to decide later"
+
+	return title + "\n" + warning + "\n" + options
+}
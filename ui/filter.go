@@ -0,0 +1,99 @@
+package ui
+
+import (
+	"sort"
+
+	"donut/models"
+)
+
+type tagCount struct {
+	name  string
+	count int
+}
+
+// matchesFilter reports whether todo or one of its subtasks carries the
+// filter tag. An empty filter matches every todo.
+func matchesFilter(todo *models.Todo, filter string) bool {
+	if filter == "" || todo.HasTag(filter) {
+		return true
+	}
+	for i := range todo.Children {
+		if matchesFilter(&todo.Children[i], filter) {
+			return true
+		}
+	}
+	return false
+}
+
+// allTags returns every tag and context used across all projects, with
+// the number of todos carrying it.
+func (m *Model) allTags() []tagCount {
+	counts := make(map[string]int)
+	for _, project := range m.data.Projects {
+		models.CountTags(project.Todos, counts)
+	}
+
+	tags := make([]tagCount, 0, len(counts))
+	for name, count := range counts {
+		tags = append(tags, tagCount{name: name, count: count})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].name < tags[j].name
+	})
+	return tags
+}
+
+func (m *Model) openTagFilter() {
+	m.filterReturn = m.mode
+	m.mode = TagFilterView
+	m.tagCursor = 0
+	for i, tag := range m.allTags() {
+		if tag.name == m.filter {
+			m.tagCursor = i + 1
+		}
+	}
+}
+
+// setFilter applies filter and moves the cursors to visible items.
+func (m *Model) setFilter(filter string) {
+	m.filter = filter
+	m.todoCursor = 0
+	m.inExpandedTodo = false
+	m.expandedTodoCursor = 0
+
+	if !m.projectVisible(m.projectCursor) {
+		if next := m.nextProject(-1, 1); next >= 0 {
+			m.projectCursor = next
+		}
+	}
+	if m.mode == TodoView && !m.projectVisible(m.projectCursor) {
+		m.mode = ProjectView
+	}
+}
+
+// projectVisible reports whether the project at index i has todos
+// matching the filter.
+func (m *Model) projectVisible(i int) bool {
+	if i < 0 || i >= len(m.data.Projects) {
+		return false
+	}
+	return m.filter == "" || len(m.projectRows(&m.data.Projects[i])) > 0
+}
+
+// nextProject returns the index of the next visible project after from
+// in direction step, or -1 when there is none.
+func (m *Model) nextProject(from, step int) int {
+	for i := from + step; i >= 0 && i < len(m.data.Projects); i += step {
+		if m.projectVisible(i) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *Model) filterLabel() string {
+	if m.filter == "" {
+		return ""
+	}
+	return " " + renderTag(m.filter)
+}
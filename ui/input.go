@@ -0,0 +1,220 @@
+package ui
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/rivo/uniseg"
+)
+
+// Kinds of input, each with its own history.
+const (
+	projectInput = "project"
+	todoInput    = "todo"
+	dateInput    = "date"
+)
+
+// maxHistory is the number of entries remembered per kind of input.
+const maxHistory = 100
+
+var cursorStyle = lipgloss.NewStyle().Reverse(true)
+
+// lineInput is a single line text editor. The text is kept as grapheme
+// clusters, so that moving the cursor or deleting never splits a character
+// such as "é" written with a combining accent, or an emoji.
+type lineInput struct {
+	graphemes []string
+	cursor    int
+
+	kind string
+	// histories hold the entries submitted so far, oldest first, by kind
+	histories map[string][]string
+	// recall is the history entry shown, len(history) for the text being
+	// typed, which is kept in draft while browsing
+	recall int
+	draft  string
+}
+
+// Start begins editing value, the cursor at its end.
+func (in *lineInput) Start(kind, value string) {
+	in.kind = kind
+	in.SetValue(value)
+	in.recall = len(in.histories[kind])
+	in.draft = ""
+}
+
+func (in *lineInput) Value() string {
+	return strings.Join(in.graphemes, "")
+}
+
+// SetValue replaces the text, moving the cursor to its end.
+func (in *lineInput) SetValue(value string) {
+	in.graphemes = splitGraphemes(value)
+	in.cursor = len(in.graphemes)
+}
+
+func (in *lineInput) Reset() {
+	in.SetValue("")
+}
+
+// Submit returns the text, which is added to the history of its kind,
+// and clears the input.
+func (in *lineInput) Submit() string {
+	value := in.Value()
+	if strings.TrimSpace(value) != "" {
+		if in.histories == nil {
+			in.histories = make(map[string][]string)
+		}
+		history := in.histories[in.kind]
+		if len(history) == 0 || history[len(history)-1] != value {
+			history = append(history, value)
+		}
+		if len(history) > maxHistory {
+			history = history[len(history)-maxHistory:]
+		}
+		in.histories[in.kind] = history
+	}
+	in.Reset()
+	return value
+}
+
+// Update edits the text according to a key. Keys with no meaning for the
+// editor are ignored rather than typed.
+func (in *lineInput) Update(msg tea.KeyMsg) {
+	switch msg.String() {
+	case "left", "ctrl+b":
+		in.cursor = max(in.cursor-1, 0)
+	case "right", "ctrl+f":
+		in.cursor = min(in.cursor+1, len(in.graphemes))
+	case "alt+left", "ctrl+left", "alt+b":
+		in.cursor = in.wordStart()
+	case "alt+right", "ctrl+right", "alt+f":
+		in.cursor = in.wordEnd()
+	case "home", "ctrl+a":
+		in.cursor = 0
+	case "end", "ctrl+e":
+		in.cursor = len(in.graphemes)
+	case "backspace", "ctrl+h":
+		in.delete(max(in.cursor-1, 0), in.cursor)
+	case "delete", "ctrl+d":
+		in.delete(in.cursor, min(in.cursor+1, len(in.graphemes)))
+	case "ctrl+w", "alt+backspace":
+		in.delete(in.wordStart(), in.cursor)
+	case "alt+d":
+		in.delete(in.cursor, in.wordEnd())
+	case "ctrl+u":
+		in.delete(0, in.cursor)
+	case "ctrl+k":
+		in.delete(in.cursor, len(in.graphemes))
+	case "up", "ctrl+p":
+		in.browse(-1)
+	case "down", "ctrl+n":
+		in.browse(1)
+	default:
+		switch {
+		case msg.Type == tea.KeySpace:
+			in.insert(" ")
+		case msg.Type == tea.KeyRunes && !msg.Alt:
+			in.insert(string(msg.Runes))
+		}
+	}
+}
+
+// insert types text at the cursor. Pasted line breaks and tabs become
+// spaces, as the text is a single line.
+func (in *lineInput) insert(text string) {
+	text = strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n' || r == '\r' || r == '\t':
+			return ' '
+		case unicode.IsControl(r):
+			return -1
+		}
+		return r
+	}, text)
+
+	// Segmenting again lets a combining accent join the letter before it
+	before := strings.Join(in.graphemes[:in.cursor], "") + text
+	after := strings.Join(in.graphemes[in.cursor:], "")
+	in.graphemes = splitGraphemes(before + after)
+	in.cursor = len(splitGraphemes(before))
+}
+
+// delete removes the graphemes from start to end, leaving the cursor at
+// start.
+func (in *lineInput) delete(start, end int) {
+	if start >= end {
+		return
+	}
+	in.graphemes = append(in.graphemes[:start], in.graphemes[end:]...)
+	in.cursor = start
+}
+
+// wordStart returns the start of the word before the cursor.
+func (in *lineInput) wordStart() int {
+	i := in.cursor
+	for i > 0 && isSpace(in.graphemes[i-1]) {
+		i--
+	}
+	for i > 0 && !isSpace(in.graphemes[i-1]) {
+		i--
+	}
+	return i
+}
+
+// wordEnd returns the end of the word after the cursor.
+func (in *lineInput) wordEnd() int {
+	i := in.cursor
+	for i < len(in.graphemes) && isSpace(in.graphemes[i]) {
+		i++
+	}
+	for i < len(in.graphemes) && !isSpace(in.graphemes[i]) {
+		i++
+	}
+	return i
+}
+
+// browse shows the previous, or next, entry of the history.
+func (in *lineInput) browse(step int) {
+	history := in.histories[in.kind]
+	recall := in.recall + step
+	if recall < 0 || recall > len(history) {
+		return
+	}
+	if in.recall == len(history) {
+		in.draft = in.Value()
+	}
+	in.recall = recall
+	if recall == len(history) {
+		in.SetValue(in.draft)
+	} else {
+		in.SetValue(history[recall])
+	}
+}
+
+// View renders the text with the cursor over the grapheme it is on.
+func (in lineInput) View() string {
+	before := strings.Join(in.graphemes[:in.cursor], "")
+	if in.cursor == len(in.graphemes) {
+		return inputStyle.Render(before + "█")
+	}
+	after := strings.Join(in.graphemes[in.cursor+1:], "")
+	return inputStyle.Render(before) + cursorStyle.Render(in.graphemes[in.cursor]) + inputStyle.Render(after)
+}
+
+func splitGraphemes(text string) []string {
+	var graphemes []string
+	state := -1
+	for text != "" {
+		var cluster string
+		cluster, text, _, state = uniseg.FirstGraphemeClusterInString(text, state)
+		graphemes = append(graphemes, cluster)
+	}
+	return graphemes
+}
+
+func isSpace(grapheme string) bool {
+	return strings.TrimSpace(grapheme) == ""
+}
@@ -0,0 +1,119 @@
+package ui
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/bubbletea"
+)
+
+// namedKeys are the keys the tests press by name, other names are typed.
+var namedKeys = map[string]tea.KeyMsg{
+	"left":      {Type: tea.KeyLeft},
+	"right":     {Type: tea.KeyRight},
+	"home":      {Type: tea.KeyHome},
+	"end":       {Type: tea.KeyEnd},
+	"up":        {Type: tea.KeyUp},
+	"down":      {Type: tea.KeyDown},
+	"backspace": {Type: tea.KeyBackspace},
+	"delete":    {Type: tea.KeyDelete},
+	"space":     {Type: tea.KeySpace, Runes: []rune{' '}},
+	"ctrl+w":    {Type: tea.KeyCtrlW},
+	"ctrl+u":    {Type: tea.KeyCtrlU},
+	"ctrl+k":    {Type: tea.KeyCtrlK},
+	"alt+b":     {Type: tea.KeyRunes, Runes: []rune{'b'}, Alt: true},
+	"alt+f":     {Type: tea.KeyRunes, Runes: []rune{'f'}, Alt: true},
+	"alt+d":     {Type: tea.KeyRunes, Runes: []rune{'d'}, Alt: true},
+	"alt+x":     {Type: tea.KeyRunes, Runes: []rune{'x'}, Alt: true},
+}
+
+func press(in *lineInput, keys ...string) {
+	for _, key := range keys {
+		msg, ok := namedKeys[key]
+		if !ok {
+			msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+		}
+		in.Update(msg)
+	}
+}
+
+func TestLineInput(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  string
+		keys   []string
+		want   string
+		cursor int
+	}{
+		{"type", "", []string{"a", "space", "b"}, "a b", 3},
+		{"insert in the middle", "ac", []string{"left", "b"}, "abc", 2},
+		{"backspace", "abc", []string{"backspace"}, "ab", 2},
+		{"backspace at the start", "abc", []string{"home", "backspace"}, "abc", 0},
+		{"delete", "abc", []string{"home", "delete"}, "bc", 0},
+		{"delete at the end", "abc", []string{"delete"}, "abc", 3},
+		{"backspace a two byte letter", "café", []string{"backspace"}, "caf", 3},
+		{"backspace a combining accent", "cafe\u0301", []string{"backspace"}, "caf", 3},
+		{"move over a combining accent", "e\u0301a", []string{"left", "left", "delete"}, "a", 0},
+		{"backspace an emoji", "ok 👍🏽", []string{"backspace"}, "ok ", 3},
+		{"backspace a ZWJ sequence", "a👩\u200d💻b", []string{"left", "backspace"}, "ab", 1},
+		{"backspace a flag", "🇫🇷!", []string{"left", "backspace"}, "!", 0},
+		{"wide characters", "日本語", []string{"left", "backspace"}, "日語", 1},
+		{"word back", "call bob now", []string{"alt+b", "alt+b", "x"}, "call xbob now", 6},
+		{"word forward", "call bob now", []string{"home", "alt+f", "alt+f", "x"}, "call bobx now", 9},
+		{"word motion keeps punctuation", "see example.com/page now", []string{"alt+b", "alt+b", "|"}, "see |example.com/page now", 5},
+		{"word motion over spaces", "a   b", []string{"alt+b", "alt+b"}, "a   b", 0},
+		{"delete word back", "call bob now", []string{"ctrl+w"}, "call bob ", 9},
+		{"delete word back over spaces", "call bob  ", []string{"ctrl+w"}, "call ", 5},
+		{"delete word forward", "call bob now", []string{"home", "alt+d"}, " bob now", 0},
+		{"delete word with emoji", "ship it 🚀🚀", []string{"ctrl+w"}, "ship it ", 8},
+		{"delete to the start", "call bob", []string{"left", "ctrl+u"}, "b", 0},
+		{"delete to the end", "call bob", []string{"home", "right", "ctrl+k"}, "c", 1},
+		{"alt keys are not typed", "a", []string{"alt+x"}, "a", 1},
+		{"pasted line breaks", "", []string{"a\nb\tc"}, "a b c", 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var in lineInput
+			in.Start(todoInput, tt.value)
+			press(&in, tt.keys...)
+			if got := in.Value(); got != tt.want {
+				t.Errorf("value = %q, want %q", got, tt.want)
+			}
+			if in.cursor != tt.cursor {
+				t.Errorf("cursor = %d, want %d", in.cursor, tt.cursor)
+			}
+		})
+	}
+}
+
+func TestLineInputHistory(t *testing.T) {
+	var in lineInput
+	for _, value := range []string{"first", "second", "second", " "} {
+		in.Start(todoInput, value)
+		in.Submit()
+	}
+
+	tests := []struct {
+		name string
+		kind string
+		keys []string
+		want string
+	}{
+		{"previous entry", todoInput, []string{"up"}, "second"},
+		{"duplicates and blanks are skipped", todoInput, []string{"up", "up"}, "first"},
+		{"stops at the oldest entry", todoInput, []string{"up", "up", "up"}, "first"},
+		{"back to the draft", todoInput, []string{"d", "r", "a", "f", "t", "up", "up", "down", "down"}, "draft"},
+		{"nothing after the draft", todoInput, []string{"down"}, ""},
+		{"history per kind", projectInput, []string{"up"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in.Start(tt.kind, "")
+			press(&in, tt.keys...)
+			if got := in.Value(); got != tt.want {
+				t.Errorf("value = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
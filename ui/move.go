@@ -0,0 +1,89 @@
+package ui
+
+import (
+	"fmt"
+
+	"donut/models"
+)
+
+// moveTodo moves the selected todo up (-1) or down (1) among its visible
+// siblings. Todos can only be moved in file order, which the project is
+// switched to.
+func (m *Model) moveTodo(step int) {
+	project := m.getCurrentProject()
+	if project == nil {
+		return
+	}
+
+	if mode := m.projectSortMode(project); mode != models.SortManual {
+		m.followTodo(func() {
+			m.state.SortModes[project.Filename] = models.SortManual.String()
+		})
+		m.message = fmt.Sprintf("Switched from sorting by %s to file order", sortLabels[mode])
+		if err := m.state.Save(); err != nil {
+			m.message = fmt.Sprintf("Could not remember the sort order: %v", err)
+		}
+	}
+
+	row, ok := rowAt(m.projectRows(project), m.selectedRow())
+	if !ok {
+		return
+	}
+	siblings := *row.siblings
+	from, to := row.index, row.index+step
+	for to >= 0 && to < len(siblings) && !matchesFilter(&siblings[to], m.filter) {
+		to += step
+	}
+	if to < 0 || to >= len(siblings) {
+		return
+	}
+
+	todo := siblings[from]
+	if to > from {
+		copy(siblings[from:to], siblings[from+1:to+1])
+	} else {
+		copy(siblings[to+1:from+1], siblings[to:from])
+	}
+	siblings[to] = todo
+
+	if i := rowIndex(m.projectRows(project), &siblings[to]); i >= 0 {
+		m.setSelectedRow(i)
+	}
+	m.saveProject(project, "move todo")
+}
+
+// moveProject moves the selected project up (-1) or down (1) past the
+// next visible project, and saves the new order of the projects.
+func (m *Model) moveProject(step int) {
+	from := m.projectCursor
+	to := m.nextProject(from, step)
+	if from >= len(m.data.Projects) || to < 0 {
+		return
+	}
+
+	projects := m.data.Projects
+	project := projects[from]
+	expanded := m.expandedProjects[from]
+	if to > from {
+		copy(projects[from:to], projects[from+1:to+1])
+		for i := from; i < to; i++ {
+			m.expandedProjects[i] = m.expandedProjects[i+1]
+		}
+	} else {
+		copy(projects[to+1:from+1], projects[to:from])
+		for i := from; i > to; i-- {
+			m.expandedProjects[i] = m.expandedProjects[i-1]
+		}
+	}
+	projects[to] = project
+	m.expandedProjects[to] = expanded
+	m.projectCursor = to
+
+	filenames := make([]string, len(projects))
+	for i := range projects {
+		filenames[i] = projects[i].Filename
+	}
+	if err := m.storage.SaveOrder(filenames); err != nil {
+		m.message = fmt.Sprintf("Could not save the order of the projects: %v", err)
+	}
+}
@@ -0,0 +1,172 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// notesEditedMsg reports that the editor editing the notes of a todo in a
+// temporary file exited.
+type notesEditedMsg struct {
+	filename string
+	id       string
+	path     string
+	err      error
+}
+
+// openNotes starts editing the notes of the selected todo in the text
+// area.
+func (m *Model) openNotes() {
+	project := m.getCurrentProject()
+	row, ok := rowAt(m.projectRows(project), m.todoCursor)
+	if !ok {
+		return
+	}
+	m.mode = EditNotesView
+	m.notesFilename = project.Filename
+	m.notesID = row.todo.ID
+	m.notes.Start(row.todo.Notes)
+	m.inputMode = true
+}
+
+// submitNotes saves the notes typed in the text area.
+func (m *Model) submitNotes() {
+	m.setNotes(m.notesFilename, m.notesID, m.notes.Value())
+	m.mode = TodoView
+	m.inputMode = false
+	m.notes.Reset()
+}
+
+// setNotes replaces the notes of the todo with the given ID, when it is
+// still around.
+func (m *Model) setNotes(filename, id, notes string) {
+	index := m.projectIndex(filename)
+	if index < 0 {
+		m.message = "The project of the todo is gone, the notes were not saved"
+		return
+	}
+	project := &m.data.Projects[index]
+	todos, i := project.FindByID(id)
+	if todos == nil {
+		m.message = "The todo is gone, the notes were not saved"
+		return
+	}
+
+	todo := &(*todos)[i]
+	edited := *todo
+	edited.SetNotes(notes)
+	if edited.Notes == todo.Notes {
+		return
+	}
+	m.followTodo(func() {
+		todo.Notes = edited.Notes
+		m.saveProject(project, "edit notes")
+	})
+}
+
+// editNotesExternally writes the notes of the selected todo to a temporary
+// file and opens it in the editor of the user.
+func (m *Model) editNotesExternally() tea.Cmd {
+	project := m.getCurrentProject()
+	row, ok := rowAt(m.projectRows(project), m.todoCursor)
+	if !ok {
+		return nil
+	}
+
+	editor := strings.Fields(os.Getenv("VISUAL"))
+	if len(editor) == 0 {
+		editor = strings.Fields(os.Getenv("EDITOR"))
+	}
+	if len(editor) == 0 {
+		editor = []string{"vi"}
+	}
+
+	file, err := os.CreateTemp("", "donut-notes-*.md")
+	if err != nil {
+		m.message = fmt.Sprintf("Could not create a file for the notes: %v", err)
+		return nil
+	}
+	_, err = file.WriteString(row.todo.Notes + "\n")
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		m.message = fmt.Sprintf("Could not write the notes: %v", err)
+		return nil
+	}
+
+	msg := notesEditedMsg{filename: project.Filename, id: row.todo.ID, path: file.Name()}
+	cmd := exec.Command(editor[0], append(editor[1:], file.Name())...)
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		msg.err = err
+		return msg
+	})
+}
+
+// finishNotes saves the notes once the editor exits.
+func (m *Model) finishNotes(msg notesEditedMsg) {
+	defer os.Remove(msg.path)
+	if msg.err != nil {
+		m.message = fmt.Sprintf("The editor failed, the notes were not saved: %v", msg.err)
+		return
+	}
+	content, err := os.ReadFile(msg.path)
+	if err != nil {
+		m.message = fmt.Sprintf("Could not read the notes: %v", err)
+		return
+	}
+	m.setNotes(msg.filename, msg.id, string(content))
+}
+
+// renderNotes renders the notes of the selected todo for the pane below
+// the todos.
+func (m Model) renderNotes() string {
+	row, ok := rowAt(m.projectRows(m.getCurrentProject()), m.todoCursor)
+	if !ok {
+		return ""
+	}
+
+	notes := mutedStyle.Render("No notes. Press N to write some, or E to use your editor.")
+	if row.todo.Notes != "" {
+		lines := strings.Split(row.todo.Notes, "\n")
+		for i, line := range lines {
+			lines[i] = "  " + renderTitle(line, lipgloss.NewStyle())
+		}
+		notes = strings.Join(lines, "\n")
+	}
+	return "\n\n" + titleStyle.Render("Notes · "+row.todo.Title) + "\n" + notes
+}
+
+func (m Model) handleEditNotesKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc":
+		m.mode = TodoView
+		m.inputMode = false
+		m.notes.Reset()
+	case "ctrl+s":
+		m.submitNotes()
+	default:
+		m.notes.Update(msg)
+	}
+	return m, nil
+}
+
+func (m Model) renderEditNotesView() string {
+	title := titleStyle.Render("Edit Notes")
+	if index := m.projectIndex(m.notesFilename); index >= 0 {
+		if todos, i := m.data.Projects[index].FindByID(m.notesID); todos != nil {
+			title = titleStyle.Render("Notes · " + (*todos)[i].Title)
+		}
+	}
+	help := mutedStyle.Render("\n\nctrl+s (save), esc (cancel), enter (new line)")
+
+	return title + "\n" + m.notes.View() + help + m.renderMessage()
+}
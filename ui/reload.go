@@ -0,0 +1,231 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"donut/models"
+
+	"github.com/charmbracelet/bubbletea"
+)
+
+// projectChangedMsg reports a project changed on disk by another program.
+type projectChangedMsg struct {
+	filename string
+}
+
+// waitForChange returns a command delivering the next change reported by
+// the storage watcher.
+func waitForChange(changes <-chan string) tea.Cmd {
+	return func() tea.Msg {
+		filename, ok := <-changes
+		if !ok {
+			return nil
+		}
+		return projectChangedMsg{filename: filename}
+	}
+}
+
+func (m *Model) projectIndex(filename string) int {
+	for i := range m.data.Projects {
+		if m.data.Projects[i].Filename == filename {
+			return i
+		}
+	}
+	return -1
+}
+
+// reloadProject replaces a project with its version on disk, keeping the
+// cursor on the same project and todo.
+func (m *Model) reloadProject(filename string) {
+	index := m.projectIndex(filename)
+	if index >= 0 && m.data.Projects[index].Dirty {
+		if m.conflict == filename {
+			// Already waiting for the user to pick a version
+			return
+		}
+		// Our last save failed, the next one will merge or report
+		// a conflict
+		next := "they will be merged on the next save"
+		if m.config.Backend == "sqlite" {
+			// The database has no merge, only the conflict prompt
+			next = "the next save will ask which version to keep"
+		}
+		m.message = fmt.Sprintf("%s changed on disk but has unsaved changes here; %s",
+			m.data.Projects[index].Name, next)
+		return
+	}
+
+	project, err := m.storage.LoadProject(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		if index >= 0 {
+			m.message = fmt.Sprintf("%s was deleted on disk", m.data.Projects[index].Name)
+			m.removeProject(index)
+		}
+		m.setSaved(filename, nil)
+		m.forgetHistory(filename)
+		return
+	}
+	if err != nil {
+		m.message = fmt.Sprintf("Could not reload %s: %v", filename, err)
+		return
+	}
+
+	// Todos added by other programs get an ID, written on the next save
+	project.EnsureIDs()
+	m.setSaved(filename, &project)
+	m.forgetHistory(filename)
+	if index < 0 {
+		m.data.Projects = append(m.data.Projects, project)
+		return
+	}
+
+	lost := m.replaceTodos(index, func() {
+		m.data.Projects[index] = project
+	})
+	if !lost {
+		return
+	}
+
+	switch m.mode {
+	case EditTodoView, DueDateView, ScheduledDateView, CreateSubtaskView:
+		// The input belongs to a todo that no longer exists
+		m.mode = TodoView
+		m.inputMode = false
+		m.input.Reset()
+		m.message = fmt.Sprintf("%s changed on disk and the todo you were editing is gone", project.Name)
+	}
+}
+
+// replaceTodos runs replace, which may give the project at index a new
+// set of todos, then folds the todos that were folded before and moves the
+// cursor back to the selected todo. It reports whether the selected todo
+// is gone.
+func (m *Model) replaceTodos(index int, replace func()) bool {
+	current := index == m.projectCursor
+	todos := m.data.Projects[index].Todos
+	var selected *models.Todo
+	if current {
+		if row, ok := rowAt(m.projectRows(&m.data.Projects[index]), m.selectedRow()); ok {
+			selected = row.todo
+		}
+	}
+	var collapsed []models.Todo
+	collectCollapsed(todos, &collapsed)
+
+	replace()
+
+	project := &m.data.Projects[index]
+	if len(todos) > 0 && len(project.Todos) > 0 && &todos[0] == &project.Todos[0] {
+		// The todos were kept, and so were the cursor and folds
+		return false
+	}
+
+	restoreCollapsed(project.Todos, collapsed)
+	if !current {
+		return false
+	}
+
+	rows := m.projectRows(project)
+
+	var found *models.Todo
+	if selected != nil {
+		found = findSameTodo(project.Todos, selected)
+	}
+	if i := rowIndex(rows, found); found != nil && i >= 0 {
+		m.setSelectedRow(i)
+		return false
+	}
+
+	m.setSelectedRow(min(m.selectedRow(), len(rows)-1))
+	if len(rows) == 0 {
+		m.inExpandedTodo = false
+	}
+	return selected != nil
+}
+
+// removeProject drops a project deleted on disk, keeping the cursor on the
+// same project when possible.
+func (m *Model) removeProject(index int) {
+	m.data.Projects = append(m.data.Projects[:index], m.data.Projects[index+1:]...)
+
+	expanded := make(map[int]bool)
+	for i, open := range m.expandedProjects {
+		switch {
+		case i < index:
+			expanded[i] = open
+		case i > index:
+			expanded[i-1] = open
+		}
+	}
+	m.expandedProjects = expanded
+
+	switch {
+	case index < m.projectCursor:
+		m.projectCursor--
+	case index == m.projectCursor:
+		if m.mode != ProjectView && m.mode != HelpView {
+			m.mode = ProjectView
+			m.inputMode = false
+			m.input.Reset()
+		}
+		m.inExpandedTodo = false
+		m.expandedTodoCursor = 0
+		if m.projectCursor >= len(m.data.Projects) {
+			m.projectCursor = max(len(m.data.Projects)-1, 0)
+		}
+	}
+}
+
+// selectedRow returns the cursor of the view showing the current project.
+func (m *Model) selectedRow() int {
+	if m.mode == ProjectView {
+		return m.expandedTodoCursor
+	}
+	return m.todoCursor
+}
+
+func (m *Model) setSelectedRow(i int) {
+	i = max(i, 0)
+	if m.mode == ProjectView {
+		m.expandedTodoCursor = i
+		return
+	}
+	m.todoCursor = i
+}
+
+// findSameTodo finds the todo with the ID of todo, or with its title and
+// creation date when it has no ID.
+func findSameTodo(todos []models.Todo, todo *models.Todo) *models.Todo {
+	for i := range todos {
+		if todo.ID != "" && todos[i].ID == todo.ID {
+			return &todos[i]
+		}
+		if todo.ID == "" && todos[i].Title == todo.Title && todos[i].CreatedAt.Equal(todo.CreatedAt) {
+			return &todos[i]
+		}
+		if found := findSameTodo(todos[i].Children, todo); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func collectCollapsed(todos []models.Todo, collapsed *[]models.Todo) {
+	for i := range todos {
+		if todos[i].Collapsed {
+			*collapsed = append(*collapsed, todos[i])
+		}
+		collectCollapsed(todos[i].Children, collapsed)
+	}
+}
+
+// restoreCollapsed folds the reloaded todos that were folded before.
+func restoreCollapsed(todos []models.Todo, collapsed []models.Todo) {
+	for i := range collapsed {
+		if todo := findSameTodo(todos, &collapsed[i]); todo != nil {
+			todo.Collapsed = true
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"donut/storage"
+
+	"github.com/charmbracelet/bubbletea"
+)
+
+func (m *Model) openRename() {
+	project := m.getCurrentProject()
+	if project == nil {
+		return
+	}
+	m.mode = RenameProjectView
+	m.input.Start(projectInput, project.Name)
+	m.inputMode = true
+}
+
+// submitRename renames the current project to the name typed, asking first
+// whether its file should be renamed too when the name gives another
+// filename.
+func (m *Model) submitRename() {
+	project := m.getCurrentProject()
+	name := strings.TrimSpace(m.input.Submit())
+	m.mode = ProjectView
+	m.inputMode = false
+	if project == nil || name == "" || name == project.Name {
+		return
+	}
+
+	// Names taken by other projects get a numeric suffix
+	filename := storage.ProjectFilename(m.storage, m.config, m.data, name, project)
+	if filename == project.Filename {
+		m.renameProject(name, "")
+		return
+	}
+
+	m.mode = RenameFileView
+	m.renameName = name
+	m.renameFilename = filename
+}
+
+// renameProject gives the current project a new name, and moves it to
+// filename unless filename is empty.
+func (m *Model) renameProject(name, filename string) {
+	project := m.getCurrentProject()
+	if project == nil {
+		return
+	}
+	index := m.projectCursor
+	from := project.Filename
+	before := m.saved[from]
+
+	project.Name = name
+	if filename == "" {
+		m.saveProject(project, "rename project")
+		return
+	}
+
+	// The title is saved under the old filename first, so that a failed
+	// rename leaves a consistent project behind
+	m.writeProject(project)
+	if project.Dirty {
+		return
+	}
+	if err := m.renameFile(index, filename); err != nil {
+		m.record("rename project", from, index, project)
+		m.message = fmt.Sprintf("Renamed to %s, but could not rename %s: %v", name, from, err)
+		return
+	}
+
+	project = &m.data.Projects[index]
+	after := project.Clone()
+	m.push("rename project", projectChange{filename: filename, index: index, before: before, after: &after, renamed: from})
+	m.setSaved(filename, project)
+	if m.message == "" {
+		m.message = fmt.Sprintf("Renamed to %s, now stored in %s", name, filename)
+	}
+}
+
+// renameFile moves the project at index to filename, along with what is
+// remembered under its old filename, and reloads the projects whose links
+// to it were updated.
+func (m *Model) renameFile(index int, filename string) error {
+	project := &m.data.Projects[index]
+	from := project.Filename
+	changed, err := m.storage.RenameProject(project, filename)
+	if err != nil {
+		return err
+	}
+
+	if saved, ok := m.saved[from]; ok {
+		m.saved[filename] = saved
+		delete(m.saved, from)
+	}
+	if mode, ok := m.state.SortModes[from]; ok {
+		m.state.SortModes[filename] = mode
+		delete(m.state.SortModes, from)
+		if err := m.state.Save(); err != nil {
+			m.message = fmt.Sprintf("Could not remember the sort order of %s: %v", project.Name, err)
+		}
+	}
+
+	for _, changedFile := range changed {
+		m.reloadProject(changedFile)
+	}
+	return nil
+}
+
+// moveFile renames the file of a project to undo or redo a rename. A
+// project deleted since has nothing to rename and is restored under the
+// filename.
+func (m *Model) moveFile(from, to string) error {
+	i := m.projectIndex(from)
+	if i < 0 {
+		return nil
+	}
+	return m.renameFile(i, to)
+}
+
+func (m Model) handleRenameProjectKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc":
+		m.mode = ProjectView
+		m.inputMode = false
+		m.input.Reset()
+	case "enter":
+		m.submitRename()
+	default:
+		m.input.Update(msg)
+	}
+	return m, nil
+}
+
+func (m Model) handleRenameFileKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "q":
+		return m, tea.Quit
+	case "esc":
+		m.mode = ProjectView
+	case "y", "enter":
+		m.mode = ProjectView
+		m.renameProject(m.renameName, m.renameFilename)
+	case "n":
+		m.mode = ProjectView
+		m.renameProject(m.renameName, "")
+	}
+	return m, nil
+}
+
+func (m Model) renderRenameProjectView() string {
+	title := titleStyle.Render("Rename Project")
+	prompt := "Project name: "
+	input := m.input.View()
+	help := "\nPress Enter to rename, Esc to cancel"
+
+	return title + "\n" + prompt + input + help + m.renderMessage()
+}
+
+func (m Model) renderRenameFileView() string {
+	project := m.getCurrentProject()
+	if project == nil {
+		return "No project selected"
+	}
+
+	// Only the markdown backend keeps projects as files in a directory
+	where := "every project"
+	object := "the filename"
+	if m.config.Backend == "" || m.config.Backend == "markdown" {
+		where = m.config.DonutDir
+		object = "the file"
+	}
+
+	title := titleStyle.Render("Rename Project")
+	question := fmt.Sprintf("Rename %s to %s as well?\nWiki-links to %s in %s are updated to the new name.",
+		project.Filename, m.renameFilename, strings.TrimSuffix(project.Filename, ".md"), where)
+	options := fmt.Sprintf("\nPress 'y' or Enter to rename %s, 'n' to only change the title, Esc to cancel", object)
+
+	return title + "\n" + question + options
+}
@@ -0,0 +1,51 @@
+package ui
+
+import (
+	"fmt"
+
+	"donut/models"
+)
+
+// sortLabels describe the sort modes in the title of the todo view.
+var sortLabels = map[models.SortMode]string{
+	models.SortManual:     "file order",
+	models.SortByCreated:  "creation date",
+	models.SortByDue:      "due date",
+	models.SortByPriority: "priority",
+	models.SortByTitle:    "title",
+}
+
+// projectSortMode returns the order the todos of project are shown in:
+// the one picked for the project, or else the one of the config.
+func (m *Model) projectSortMode(project *models.Project) models.SortMode {
+	if mode, ok := m.state.SortModes[project.Filename]; ok {
+		return models.ParseSortMode(mode)
+	}
+	return models.ParseSortMode(m.config.SortBy)
+}
+
+// cycleSortMode shows the todos of the current project in the next sort
+// mode, keeping the cursor on the same todo, and remembers the mode for
+// the project.
+func (m *Model) cycleSortMode() {
+	project := m.getCurrentProject()
+	if project == nil {
+		return
+	}
+
+	current := m.projectSortMode(project)
+	next := models.SortModes[0]
+	for i, mode := range models.SortModes {
+		if mode == current {
+			next = models.SortModes[(i+1)%len(models.SortModes)]
+		}
+	}
+
+	m.followTodo(func() {
+		m.state.SortModes[project.Filename] = next.String()
+	})
+	m.message = fmt.Sprintf("Sorted by %s", sortLabels[next])
+	if err := m.state.Save(); err != nil {
+		m.message = fmt.Sprintf("Sorted by %s, but could not remember it: %v", sortLabels[next], err)
+	}
+}
@@ -0,0 +1,142 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbletea"
+)
+
+// textArea is a multi-line text editor, made of a line editor per line.
+// Enter breaks the line at the cursor and the arrows move between lines.
+type textArea struct {
+	lines []lineInput
+	row   int
+}
+
+// Start begins editing value, the cursor at its end.
+func (ta *textArea) Start(value string) {
+	ta.lines = nil
+	for _, line := range strings.Split(value, "\n") {
+		var in lineInput
+		in.SetValue(line)
+		ta.lines = append(ta.lines, in)
+	}
+	ta.row = len(ta.lines) - 1
+}
+
+func (ta *textArea) Value() string {
+	lines := make([]string, len(ta.lines))
+	for i := range ta.lines {
+		lines[i] = ta.lines[i].Value()
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (ta *textArea) Reset() {
+	ta.Start("")
+}
+
+// Update edits the text according to a key.
+func (ta *textArea) Update(msg tea.KeyMsg) {
+	line := &ta.lines[ta.row]
+	switch msg.String() {
+	case "enter":
+		ta.breakLine()
+	case "up", "ctrl+p":
+		ta.moveRow(-1)
+	case "down", "ctrl+n":
+		ta.moveRow(1)
+	case "left", "ctrl+b":
+		if line.cursor == 0 && ta.row > 0 {
+			ta.row--
+			ta.lines[ta.row].cursor = len(ta.lines[ta.row].graphemes)
+			return
+		}
+		line.Update(msg)
+	case "right", "ctrl+f":
+		if line.cursor == len(line.graphemes) && ta.row < len(ta.lines)-1 {
+			ta.row++
+			ta.lines[ta.row].cursor = 0
+			return
+		}
+		line.Update(msg)
+	case "backspace", "ctrl+h":
+		if line.cursor == 0 && ta.row > 0 {
+			ta.row--
+			ta.joinNext()
+			return
+		}
+		line.Update(msg)
+	case "delete", "ctrl+d":
+		if line.cursor == len(line.graphemes) && ta.row < len(ta.lines)-1 {
+			ta.joinNext()
+			return
+		}
+		line.Update(msg)
+	case "tab":
+		line.insert("  ")
+	default:
+		if msg.Type == tea.KeyRunes && strings.ContainsAny(string(msg.Runes), "\r\n") {
+			ta.paste(string(msg.Runes))
+			return
+		}
+		line.Update(msg)
+	}
+}
+
+// breakLine moves the text after the cursor to a new line.
+func (ta *textArea) breakLine() {
+	line := &ta.lines[ta.row]
+	var next lineInput
+	next.graphemes = append([]string(nil), line.graphemes[line.cursor:]...)
+	line.graphemes = line.graphemes[:line.cursor]
+
+	ta.lines = append(ta.lines[:ta.row+1], append([]lineInput{next}, ta.lines[ta.row+1:]...)...)
+	ta.row++
+}
+
+// joinNext appends the next line to the current one, leaving the cursor
+// where they meet.
+func (ta *textArea) joinNext() {
+	line := &ta.lines[ta.row]
+	cursor := len(line.graphemes)
+	line.SetValue(line.Value() + ta.lines[ta.row+1].Value())
+	line.cursor = min(cursor, len(line.graphemes))
+	ta.lines = append(ta.lines[:ta.row+1], ta.lines[ta.row+2:]...)
+}
+
+// moveRow moves the cursor to the line above, or below, keeping its column
+// as far as the line allows.
+func (ta *textArea) moveRow(step int) {
+	row := ta.row + step
+	if row < 0 || row >= len(ta.lines) {
+		return
+	}
+	ta.lines[row].cursor = min(ta.lines[ta.row].cursor, len(ta.lines[row].graphemes))
+	ta.row = row
+}
+
+// paste inserts text spanning several lines at the cursor.
+func (ta *textArea) paste(text string) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	for i, part := range strings.Split(text, "\n") {
+		if i > 0 {
+			ta.breakLine()
+		}
+		ta.lines[ta.row].insert(part)
+	}
+}
+
+// View renders the lines, the cursor on the current one.
+func (ta textArea) View() string {
+	lines := make([]string, len(ta.lines))
+	for i := range ta.lines {
+		if i == ta.row {
+			lines[i] = ta.lines[i].View()
+		} else {
+			lines[i] = inputStyle.Render(ta.lines[i].Value())
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,231 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"donut/models"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var priorityLabels = map[models.Priority]string{
+	models.PriorityHighest: "!!!",
+	models.PriorityHigh:    "!!",
+	models.PriorityMedium:  "!",
+	models.PriorityLow:     "↓",
+	models.PriorityLowest:  "⇊",
+}
+
+// todoRow is a todo as displayed in a list, flattened out of the subtask
+// tree. siblings and index locate the todo in its parent's slice, which
+// stays in file order whatever order the rows are shown in.
+type todoRow struct {
+	todo     *models.Todo
+	siblings *[]models.Todo
+	index    int
+	depth    int
+}
+
+// flattenTodos returns the rows of the visible todos sorted by mode,
+// skipping the subtasks of collapsed todos and the todos not matching
+// filter.
+func flattenTodos(todos *[]models.Todo, depth int, filter string, mode models.SortMode, rows []todoRow) []todoRow {
+	for _, i := range sortedIndexes(*todos, mode) {
+		todo := &(*todos)[i]
+		if !matchesFilter(todo, filter) {
+			continue
+		}
+		rows = append(rows, todoRow{todo: todo, siblings: todos, index: i, depth: depth})
+		if !todo.Collapsed {
+			rows = flattenTodos(&todo.Children, depth+1, filter, mode, rows)
+		}
+	}
+	return rows
+}
+
+// sortedIndexes returns the indexes of todos in the order mode shows them.
+func sortedIndexes(todos []models.Todo, mode models.SortMode) []int {
+	indexes := make([]int, len(todos))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return mode.Less(&todos[indexes[i]], &todos[indexes[j]])
+	})
+	return indexes
+}
+
+func (m *Model) projectRows(project *models.Project) []todoRow {
+	if project == nil {
+		return nil
+	}
+	return flattenTodos(&project.Todos, 0, m.filter, m.projectSortMode(project), nil)
+}
+
+// archiveRows returns the rows of the archived todos, in file order.
+func archiveRows(project *models.Project) []todoRow {
+	return flattenTodos(&project.Archive, 0, "", models.SortManual, nil)
+}
+
+func rowAt(rows []todoRow, cursor int) (todoRow, bool) {
+	if cursor < 0 || cursor >= len(rows) {
+		return todoRow{}, false
+	}
+	return rows[cursor], true
+}
+
+func rowIndex(rows []todoRow, todo *models.Todo) int {
+	for i, row := range rows {
+		if row.todo == todo {
+			return i
+		}
+	}
+	return -1
+}
+
+// followTodo runs change, which may move the selected todo to another row
+// of the current project, and then moves the cursor along with the todo.
+func (m *Model) followTodo(change func()) {
+	project := m.getCurrentProject()
+	row, ok := rowAt(m.projectRows(project), m.selectedRow())
+	change()
+	if !ok {
+		return
+	}
+	if i := rowIndex(m.projectRows(m.getCurrentProject()), row.todo); i >= 0 {
+		m.setSelectedRow(i)
+	}
+}
+
+// selectTodo moves the cursor to todo when it is visible.
+func (m *Model) selectTodo(todo *models.Todo) {
+	if i := rowIndex(m.projectRows(m.getCurrentProject()), todo); i >= 0 {
+		m.todoCursor = i
+	}
+}
+
+// renderTodoRow renders the checkbox and title of a row, indented by its
+// depth, with an expand icon on todos that have subtasks.
+func renderTodoRow(row todoRow, selected bool) string {
+	todo := row.todo
+
+	icon := " "
+	suffix := ""
+	if len(todo.Children) > 0 {
+		icon = "▼"
+		if todo.Collapsed {
+			icon = "▶"
+			completed, total := models.CountTodos(todo.Children)
+			suffix = mutedStyle.Render(fmt.Sprintf(" (%d/%d)", completed, total))
+		}
+	}
+
+	checkbox := "☐"
+	var todoText string
+	if todo.Completed {
+		checkbox = "☑"
+		todoText = completedStyle.Render(todo.Title)
+	} else if selected {
+		todoText = renderTitle(todo.Title, selectedStyle)
+	} else {
+		todoText = renderTitle(todo.Title, lipgloss.NewStyle())
+	}
+
+	if label, ok := priorityLabels[todo.Priority]; ok {
+		style := priorityStyles[todo.Priority]
+		if todo.Completed {
+			style = mutedStyle
+		}
+		todoText = style.Render(label) + " " + todoText
+	}
+
+	if dates := renderDates(todo, time.Now()); dates != "" {
+		suffix = " " + dates + suffix
+	}
+	if todo.Notes != "" {
+		suffix = " " + mutedStyle.Render("≡") + suffix
+	}
+
+	return fmt.Sprintf("%s%s %s %s%s", strings.Repeat("  ", row.depth), icon, checkbox, todoText, suffix)
+}
+
+// renderTitle renders a todo title with style, highlighting its #tags and
+// @contexts.
+func renderTitle(title string, style lipgloss.Style) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range models.TagRegex.FindAllStringSubmatchIndex(title, -1) {
+		// The match may start with the whitespace preceding the tag
+		start := loc[2]
+		b.WriteString(style.Render(title[last:start]))
+		b.WriteString(renderTag(title[start:loc[1]]))
+		last = loc[1]
+	}
+	b.WriteString(style.Render(title[last:]))
+	return b.String()
+}
+
+func renderTag(tag string) string {
+	if strings.HasPrefix(tag, "@") {
+		return contextStyle.Render(tag)
+	}
+	return tagStyle.Render(tag)
+}
+
+// renderDates renders the scheduled and due dates of a todo relative to
+// now, highlighting overdue todos and todos due today.
+func renderDates(todo *models.Todo, now time.Time) string {
+	var labels []string
+
+	if !todo.Scheduled.IsZero() {
+		labels = append(labels, mutedStyle.Render("⏳ "+relativeDate(todo.Scheduled, now, "ago")))
+	}
+
+	if !todo.Due.IsZero() {
+		label := "📅 " + relativeDate(todo.Due, now, "overdue")
+		switch {
+		case todo.Completed:
+			label = mutedStyle.Render(label)
+		case todo.IsOverdue(now):
+			label = overdueStyle.Render(label)
+		case models.DaysUntil(todo.Due, now) == 0:
+			label = dueTodayStyle.Render(label)
+		default:
+			label = mutedStyle.Render(label)
+		}
+		labels = append(labels, label)
+	}
+
+	return strings.Join(labels, " ")
+}
+
+// relativeDate describes date as "today", "in 3d" or "2d <past>".
+func relativeDate(date, now time.Time, past string) string {
+	days := models.DaysUntil(date, now)
+	switch {
+	case days == 0:
+		return "today"
+	case days > 0:
+		return fmt.Sprintf("in %dd", days)
+	default:
+		return fmt.Sprintf("%dd %s", -days, past)
+	}
+}
+
+func (m *Model) toggleRow(project *models.Project, row todoRow) {
+	if m.config.AutoCompleteParents {
+		row.todo.SetCompleted(!row.todo.Completed)
+		project.SyncCompletion()
+	} else {
+		row.todo.MarkCompleted(!row.todo.Completed)
+	}
+}
+
+func (m *Model) syncCompletion(project *models.Project) {
+	if m.config.AutoCompleteParents {
+		project.SyncCompletion()
+	}
+}
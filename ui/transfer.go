@@ -0,0 +1,139 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"donut/models"
+
+	"github.com/charmbracelet/bubbletea"
+)
+
+// targetProjects returns the indexes of the projects the selected todo can
+// be moved or copied to.
+func (m Model) targetProjects() []int {
+	var targets []int
+	for i := range m.data.Projects {
+		if i != m.projectCursor {
+			targets = append(targets, i)
+		}
+	}
+	return targets
+}
+
+// openTransfer opens the picker of the project to move the selected todo
+// to, or to copy it to when copying is set.
+func (m *Model) openTransfer(copying bool) {
+	if _, ok := rowAt(m.projectRows(m.getCurrentProject()), m.todoCursor); !ok {
+		return
+	}
+	if len(m.targetProjects()) == 0 {
+		m.message = "There is no other project, press esc and n to create one"
+		return
+	}
+	m.copying = copying
+	m.targetCursor = 0
+	m.mode = TransferTodoView
+}
+
+// transferTodo moves or copies the selected todo, with its subtasks, to the
+// project at index.
+func (m *Model) transferTodo(index int) {
+	source := m.getCurrentProject()
+	row, ok := rowAt(m.projectRows(source), m.todoCursor)
+	if !ok {
+		return
+	}
+	target := &m.data.Projects[index]
+	title := row.todo.Title
+
+	if m.copying {
+		target.CopyTodo(*row.todo)
+		m.saveProject(target, "copy todo")
+		if m.message == "" {
+			m.message = fmt.Sprintf("Copied %q to %s", title, target.Name)
+		}
+		return
+	}
+
+	sourceBefore, targetBefore := source.Clone(), target.Clone()
+	target.MoveTodo(row.siblings, row.index)
+	m.syncCompletion(source)
+	source.Dirty, target.Dirty = true, true
+
+	var err error
+	m.replaceTodos(m.projectCursor, func() {
+		// The target is written first, so the todo is never lost
+		err = m.storage.SaveProjects(target, source)
+	})
+	if err != nil {
+		// Both projects are saved together or not at all, so a conflict
+		// is reported rather than resolved for one of them
+		m.replaceTodos(m.projectCursor, func() {
+			*source = sourceBefore
+		})
+		*target = targetBefore
+		m.message = fmt.Sprintf("Could not move %q: %v", title, err)
+		return
+	}
+
+	m.push("move todo",
+		m.change(target.Filename, index, target),
+		m.change(source.Filename, m.projectCursor, source))
+	if rows := m.projectRows(source); m.todoCursor >= len(rows) {
+		m.todoCursor = max(len(rows)-1, 0)
+	}
+	m.message = fmt.Sprintf("Moved %q to %s", title, target.Name)
+}
+
+func (m Model) handleTransferKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	targets := m.targetProjects()
+	switch msg.String() {
+	case "ctrl+c", "q":
+		return m, tea.Quit
+	case "esc":
+		m.mode = TodoView
+	case "up", "k":
+		if m.targetCursor > 0 {
+			m.targetCursor--
+		}
+	case "down", "j":
+		if m.targetCursor < len(targets)-1 {
+			m.targetCursor++
+		}
+	case "enter":
+		m.mode = TodoView
+		if m.targetCursor < len(targets) {
+			m.transferTodo(targets[m.targetCursor])
+		}
+	}
+	return m, nil
+}
+
+func (m Model) renderTransferView() string {
+	row, ok := rowAt(m.projectRows(m.getCurrentProject()), m.todoCursor)
+	if !ok {
+		return "No todo selected"
+	}
+
+	action := "Move"
+	if m.copying {
+		action = "Copy"
+	}
+	title := titleStyle.Render(fmt.Sprintf("%s %q to", action, row.todo.Title))
+
+	var lines []string
+	for i, index := range m.targetProjects() {
+		project := &m.data.Projects[index]
+		completed, total := models.CountTodos(project.Todos)
+		cursor, name := " ", project.Name
+		if i == m.targetCursor {
+			cursor, name = ">", selectedStyle.Render(name)
+		}
+		lines = append(lines, fmt.Sprintf("%s %s %s", cursor, name, mutedStyle.Render(fmt.Sprintf("(%d/%d)", completed, total))))
+	}
+
+	help := mutedStyle.Render(fmt.Sprintf("\n\nenter (%s), esc (cancel)", strings.ToLower(action)))
+
+	return title + "\n" + strings.Join(lines, "\n") + help
+}
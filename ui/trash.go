@@ -0,0 +1,122 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"donut/storage"
+
+	"github.com/charmbracelet/bubbletea"
+)
+
+func (m *Model) openTrash() {
+	trash, ok := m.storage.(storage.Trash)
+	if !ok {
+		m.message = "This backend has no trash, deleted projects are gone"
+		return
+	}
+
+	entries, err := trash.TrashEntries()
+	if err != nil {
+		m.message = fmt.Sprintf("Could not read the trash: %v", err)
+		return
+	}
+	m.trashEntries = entries
+	m.trashCursor = 0
+	m.mode = TrashView
+}
+
+// restoreTrashed moves the selected project out of the trash and back
+// into the project list.
+func (m *Model) restoreTrashed() {
+	if m.trashCursor >= len(m.trashEntries) {
+		return
+	}
+	entry := m.trashEntries[m.trashCursor]
+
+	project, err := m.storage.(storage.Trash).RestoreTrashed(entry.ID)
+	if err != nil {
+		m.message = fmt.Sprintf("Could not restore %s: %v", entry.Name, err)
+		return
+	}
+
+	m.trashEntries = append(m.trashEntries[:m.trashCursor], m.trashEntries[m.trashCursor+1:]...)
+	m.trashCursor = max(min(m.trashCursor, len(m.trashEntries)-1), 0)
+
+	// Todos added before IDs existed get one, written on the next save
+	project.EnsureIDs()
+	if index := m.projectIndex(project.Filename); index >= 0 {
+		// The watcher already picked up the restored file
+		m.data.Projects[index] = project
+	} else {
+		m.data.Projects = append(m.data.Projects, project)
+	}
+	m.record("restore project", project.Filename, m.projectIndex(project.Filename), &project)
+	m.message = fmt.Sprintf("Restored %s", project.Name)
+}
+
+// purgeTrashed deletes the selected project from the trash for good.
+func (m *Model) purgeTrashed() {
+	if m.trashCursor >= len(m.trashEntries) {
+		return
+	}
+	entry := m.trashEntries[m.trashCursor]
+
+	if err := m.storage.(storage.Trash).PurgeTrashed(entry.ID); err != nil {
+		m.message = fmt.Sprintf("Could not purge %s: %v", entry.Name, err)
+		return
+	}
+
+	m.trashEntries = append(m.trashEntries[:m.trashCursor], m.trashEntries[m.trashCursor+1:]...)
+	m.trashCursor = max(min(m.trashCursor, len(m.trashEntries)-1), 0)
+	m.message = fmt.Sprintf("Purged %s", entry.Name)
+}
+
+func (m Model) handleTrashKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "q":
+		return m, tea.Quit
+	case "esc", "t":
+		m.mode = ProjectView
+	case "up", "k":
+		if m.trashCursor > 0 {
+			m.trashCursor--
+		}
+	case "down", "j":
+		if m.trashCursor < len(m.trashEntries)-1 {
+			m.trashCursor++
+		}
+	case "enter", "r":
+		m.restoreTrashed()
+	case "x":
+		m.purgeTrashed()
+	}
+	return m, nil
+}
+
+func (m Model) renderTrashView() string {
+	title := titleStyle.Render("Trash")
+
+	var lines []string
+	now := time.Now()
+	for i, entry := range m.trashEntries {
+		cursor := " "
+		name := entry.Name
+		if i == m.trashCursor {
+			cursor = ">"
+			name = selectedStyle.Render(name)
+		}
+		deleted := mutedStyle.Render(fmt.Sprintf("deleted %s", relativeDate(entry.DeletedAt, now, "ago")))
+		lines = append(lines, fmt.Sprintf("%s %s %s", cursor, name, deleted))
+	}
+
+	content := strings.Join(lines, "\n")
+	if len(m.trashEntries) == 0 {
+		content = "The trash is empty."
+	}
+
+	help := mutedStyle.Render("\n\nenter/r (restore), x (purge), esc (back)")
+
+	return title + "\n" + content + m.renderMessage() + help
+}
@@ -1,9 +1,13 @@
 package ui
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"strings"
+	"time"
 
+	"donut/config"
 	"donut/models"
 	"donut/storage"
 
@@ -18,18 +22,31 @@ const (
 	TodoView
 	CreateProjectView
 	CreateTodoView
+	CreateSubtaskView
 	EditTodoView
+	DueDateView
+	ScheduledDateView
 	HelpView
 	ConfirmDeleteProjectView
+	TagFilterView
+	TrashView
+	ArchiveView
+	TransferTodoView
+	RenameProjectView
+	RenameFileView
+	EditNotesView
 )
 
 type Model struct {
-	storage        *storage.Storage
+	config         *config.Config
+	// state is what the TUI remembers between sessions
+	state          *config.State
+	storage        storage.Backend
 	data           *models.AppData
 	mode           ViewMode
 	projectCursor  int
 	todoCursor     int
-	inputValue     string
+	input          lineInput
 	inputMode      bool
 	message        string
 	width          int
@@ -37,10 +54,47 @@ type Model struct {
 	expandedProjects map[int]bool
 	inExpandedTodo   bool
 	expandedTodoCursor int
+	// filter is the "#tag" or "@context" todos must carry to be shown,
+	// empty to show every todo
+	filter         string
+	filterReturn   ViewMode
+	tagCursor      int
+	// changes receives the projects changed on disk by other programs
+	changes        <-chan string
+	// conflict is the filename of a project whose save conflicted with
+	// changes made by another program, until the user picks a version
+	conflict       string
+	// saved holds each project as last saved, for undo
+	saved          map[string]*models.Project
+	undoStack      []undoEntry
+	redoStack      []undoEntry
+	trashEntries   []storage.TrashEntry
+	trashCursor    int
+	archiveCursor  int
+	// targetCursor selects the project the todo is moved to, or copied
+	// to when copying is set
+	targetCursor   int
+	copying        bool
+	// renameName and renameFilename are the new name and filename of the
+	// project being renamed, while asking whether to rename its file
+	renameName     string
+	renameFilename string
+	// showNotes shows the notes of the selected todo below the todos
+	showNotes      bool
+	// notes edits the notes of the todo with the ID notesID in the project
+	// notesFilename
+	notes          textArea
+	notesFilename  string
+	notesID        string
 }
 
 func NewModel() (*Model, error) {
-	s, err := storage.New()
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := storage.New(cfg)
 	if err != nil {
 		return nil, err
 	}
@@ -50,24 +104,61 @@ func NewModel() (*Model, error) {
 		return nil, err
 	}
 
+	var warnings []string
+	for _, warning := range data.Warnings {
+		warnings = append(warnings, "Warning: "+warning)
+	}
+
+	state, err := config.LoadState()
+	if err != nil {
+		warnings = append(warnings, fmt.Sprintf("Could not read the saved sort orders: %v", err))
+	}
+
+	changes, err := s.Watch()
+	if err != nil {
+		warnings = append(warnings, fmt.Sprintf("Live reload is off: %v", err))
+	}
+	if _, err := storage.PurgeExpired(s, cfg.TrashRetentionDays, time.Now()); err != nil {
+		warnings = append(warnings, fmt.Sprintf("Could not purge the trash: %v", err))
+	}
+	if _, err := storage.ArchiveExpired(s, data, cfg.ArchiveAfterDays, time.Now()); err != nil {
+		warnings = append(warnings, fmt.Sprintf("Could not archive completed todos: %v", err))
+	}
+	// Todos written without an ID get one, saved along with the next
+	// change to their project
+	for i := range data.Projects {
+		data.Projects[i].EnsureIDs()
+	}
+
+	saved := make(map[string]*models.Project)
+	for i := range data.Projects {
+		project := data.Projects[i].Clone()
+		saved[project.Filename] = &project
+	}
 
 	return &Model{
+		config:             cfg,
+		state:              state,
 		storage:            s,
 		data:               data,
 		mode:               ProjectView,
 		projectCursor:      0,
 		todoCursor:         0,
-		inputValue:         "",
 		inputMode:          false,
-		message:            "",
+		message:            strings.Join(warnings, "\n"),
 		expandedProjects:   make(map[int]bool),
 		inExpandedTodo:     false,
 		expandedTodoCursor: 0,
+		changes:            changes,
+		saved:              saved,
 	}, nil
 }
 
 func (m Model) Init() tea.Cmd {
-	return nil
+	if m.changes == nil {
+		return nil
+	}
+	return waitForChange(m.changes)
 }
 
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -78,12 +169,29 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case tea.KeyMsg:
 		return m.handleKeypress(msg)
+
+	case projectChangedMsg:
+		m.reloadProject(msg.filename)
+		return m, waitForChange(m.changes)
+
+	case notesEditedMsg:
+		m.finishNotes(msg)
 	}
 
 	return m, nil
 }
 
 func (m Model) handleKeypress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	// Messages stay until the next key is pressed, or until the input is
+	// done so that warnings remain visible while typing
+	if !m.inputMode {
+		m.message = ""
+	}
+
+	if m.conflict != "" {
+		return m.handleConflictKeys(msg)
+	}
+
 	switch m.mode {
 	case ProjectView:
 		return m.handleProjectViewKeys(msg)
@@ -93,12 +201,30 @@ func (m Model) handleKeypress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m.handleCreateProjectKeys(msg)
 	case CreateTodoView:
 		return m.handleCreateTodoKeys(msg)
+	case CreateSubtaskView:
+		return m.handleCreateSubtaskKeys(msg)
 	case EditTodoView:
 		return m.handleEditTodoKeys(msg)
+	case DueDateView, ScheduledDateView:
+		return m.handleDateInputKeys(msg)
 	case HelpView:
 		return m.handleHelpViewKeys(msg)
 	case ConfirmDeleteProjectView:
 		return m.handleConfirmDeleteProjectKeys(msg)
+	case TagFilterView:
+		return m.handleTagFilterKeys(msg)
+	case TrashView:
+		return m.handleTrashKeys(msg)
+	case ArchiveView:
+		return m.handleArchiveKeys(msg)
+	case TransferTodoView:
+		return m.handleTransferKeys(msg)
+	case RenameProjectView:
+		return m.handleRenameProjectKeys(msg)
+	case RenameFileView:
+		return m.handleRenameFileKeys(msg)
+	case EditNotesView:
+		return m.handleEditNotesKeys(msg)
 	}
 	return m, nil
 }
@@ -114,30 +240,30 @@ func (m Model) handleProjectViewKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			} else {
 				m.inExpandedTodo = false
 			}
-		} else if m.projectCursor > 0 {
-			m.projectCursor--
+		} else if prev := m.nextProject(m.projectCursor, -1); prev >= 0 {
+			m.projectCursor = prev
 			m.inExpandedTodo = false
 			m.expandedTodoCursor = 0
 		}
 	case "down", "j":
 		if m.inExpandedTodo {
-			currentProject := m.getCurrentProject()
-			if currentProject != nil && m.expandedTodoCursor < len(currentProject.Todos)-1 {
+			if m.expandedTodoCursor < len(m.projectRows(m.getCurrentProject()))-1 {
 				m.expandedTodoCursor++
 			}
-		} else if m.projectCursor < len(m.data.Projects)-1 {
-			m.projectCursor++
+		} else if next := m.nextProject(m.projectCursor, 1); next >= 0 {
+			m.projectCursor = next
 			m.inExpandedTodo = false
 			m.expandedTodoCursor = 0
 		} else if m.expandedProjects[m.projectCursor] {
-			currentProject := m.getCurrentProject()
-			if currentProject != nil && len(currentProject.Todos) > 0 {
+			if len(m.projectRows(m.getCurrentProject())) > 0 {
 				m.inExpandedTodo = true
 				m.expandedTodoCursor = 0
 			}
 		}
 	case "tab":
-		if len(m.data.Projects) > 0 {
+		if m.inExpandedTodo {
+			m.toggleCollapsed(m.expandedTodoCursor)
+		} else if len(m.data.Projects) > 0 {
 			m.expandedProjects[m.projectCursor] = !m.expandedProjects[m.projectCursor]
 			m.inExpandedTodo = false
 			m.expandedTodoCursor = 0
@@ -156,12 +282,36 @@ func (m Model) handleProjectViewKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 	case "n":
 		m.mode = CreateProjectView
-		m.inputValue = ""
+		m.input.Start(projectInput, "")
 		m.inputMode = true
 	case "d":
 		if len(m.data.Projects) > 0 {
 			m.mode = ConfirmDeleteProjectView
 		}
+	case "r":
+		m.openRename()
+	case "K", "alt+up":
+		if m.inExpandedTodo {
+			m.moveTodo(-1)
+		} else {
+			m.moveProject(-1)
+		}
+	case "J", "alt+down":
+		if m.inExpandedTodo {
+			m.moveTodo(1)
+		} else {
+			m.moveProject(1)
+		}
+	case "t":
+		m.openTrash()
+	case "f":
+		m.openTagFilter()
+	case "F":
+		m.setFilter("")
+	case "u":
+		m.undo()
+	case "ctrl+r":
+		m.redo()
 	case "?":
 		m.mode = HelpView
 	}
@@ -179,25 +329,79 @@ func (m Model) handleTodoViewKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.todoCursor--
 		}
 	case "down", "j":
-		currentProject := m.getCurrentProject()
-		if currentProject != nil && m.todoCursor < len(currentProject.Todos)-1 {
+		if m.todoCursor < len(m.projectRows(m.getCurrentProject()))-1 {
 			m.todoCursor++
 		}
+	case "tab":
+		m.toggleCollapsed(m.todoCursor)
 	case " ":
 		m.toggleTodo()
 	case "n":
 		m.mode = CreateTodoView
-		m.inputValue = ""
+		m.input.Start(todoInput, "")
 		m.inputMode = true
+	case "a":
+		if _, ok := rowAt(m.projectRows(m.getCurrentProject()), m.todoCursor); ok {
+			m.mode = CreateSubtaskView
+			m.input.Start(todoInput, "")
+			m.inputMode = true
+		}
 	case "d":
 		m.deleteTodo()
+	case "A":
+		m.archiveTodos()
+	case "v":
+		m.openArchive()
+	case "m":
+		m.openTransfer(false)
+	case "c":
+		m.openTransfer(true)
+	case "o":
+		m.cycleSortMode()
+	case "K", "alt+up":
+		m.moveTodo(-1)
+	case "J", "alt+down":
+		m.moveTodo(1)
+	case "+", "=":
+		m.changePriority(1)
+	case "-":
+		m.changePriority(-1)
+	case "D", "S":
+		if row, ok := rowAt(m.projectRows(m.getCurrentProject()), m.todoCursor); ok {
+			m.mode = DueDateView
+			date := row.todo.Due
+			if msg.String() == "S" {
+				m.mode = ScheduledDateView
+				date = row.todo.Scheduled
+			}
+			value := ""
+			if !date.IsZero() {
+				value = date.Format("2006-01-02")
+			}
+			m.input.Start(dateInput, value)
+			m.inputMode = true
+			m.message = ""
+		}
 	case "e":
-		currentProject := m.getCurrentProject()
-		if currentProject != nil && len(currentProject.Todos) > 0 {
+		if row, ok := rowAt(m.projectRows(m.getCurrentProject()), m.todoCursor); ok {
 			m.mode = EditTodoView
-			m.inputValue = currentProject.Todos[m.todoCursor].Title
+			m.input.Start(todoInput, row.todo.Title)
 			m.inputMode = true
 		}
+	case "i":
+		m.showNotes = !m.showNotes
+	case "N":
+		m.openNotes()
+	case "E":
+		return m, m.editNotesExternally()
+	case "f":
+		m.openTagFilter()
+	case "F":
+		m.setFilter("")
+	case "u":
+		m.undo()
+	case "ctrl+r":
+		m.redo()
 	case "?":
 		m.mode = HelpView
 	}
@@ -206,75 +410,136 @@ func (m Model) handleTodoViewKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 func (m Model) handleCreateProjectKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
-	case "ctrl+c", "q":
+	case "ctrl+c":
 		return m, tea.Quit
 	case "esc":
 		m.mode = ProjectView
 		m.inputMode = false
-		m.inputValue = ""
+		m.input.Reset()
 	case "enter":
-		if strings.TrimSpace(m.inputValue) != "" {
+		if strings.TrimSpace(m.input.Value()) != "" {
 			m.createProject()
 		}
 		m.mode = ProjectView
 		m.inputMode = false
-		m.inputValue = ""
-	case "backspace":
-		if len(m.inputValue) > 0 {
-			m.inputValue = m.inputValue[:len(m.inputValue)-1]
-		}
+		m.input.Submit()
 	default:
-		m.inputValue += msg.String()
+		m.input.Update(msg)
 	}
 	return m, nil
 }
 
 func (m Model) handleCreateTodoKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
-	case "ctrl+c", "q":
+	case "ctrl+c":
 		return m, tea.Quit
 	case "esc":
 		m.mode = TodoView
 		m.inputMode = false
-		m.inputValue = ""
+		m.input.Reset()
 	case "enter":
-		if strings.TrimSpace(m.inputValue) != "" {
+		if strings.TrimSpace(m.input.Value()) != "" {
 			m.createTodo()
 		}
 		m.mode = TodoView
 		m.inputMode = false
-		m.inputValue = ""
-	case "backspace":
-		if len(m.inputValue) > 0 {
-			m.inputValue = m.inputValue[:len(m.inputValue)-1]
+		m.input.Submit()
+	default:
+		m.input.Update(msg)
+	}
+	return m, nil
+}
+
+func (m Model) handleCreateSubtaskKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc":
+		m.mode = TodoView
+		m.inputMode = false
+		m.input.Reset()
+	case "enter":
+		if strings.TrimSpace(m.input.Value()) != "" {
+			m.createSubtask()
 		}
+		m.mode = TodoView
+		m.inputMode = false
+		m.input.Submit()
 	default:
-		m.inputValue += msg.String()
+		m.input.Update(msg)
 	}
 	return m, nil
 }
 
 func (m Model) handleEditTodoKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
-	case "ctrl+c", "q":
+	case "ctrl+c":
 		return m, tea.Quit
 	case "esc":
 		m.mode = TodoView
 		m.inputMode = false
-		m.inputValue = ""
+		m.input.Reset()
 	case "enter":
-		if strings.TrimSpace(m.inputValue) != "" {
+		if strings.TrimSpace(m.input.Value()) != "" {
 			m.editTodo()
 		}
 		m.mode = TodoView
 		m.inputMode = false
-		m.inputValue = ""
-	case "backspace":
-		if len(m.inputValue) > 0 {
-			m.inputValue = m.inputValue[:len(m.inputValue)-1]
+		m.input.Submit()
+	default:
+		m.input.Update(msg)
+	}
+	return m, nil
+}
+
+func (m Model) handleDateInputKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc":
+		m.mode = TodoView
+		m.inputMode = false
+		m.input.Reset()
+	case "enter":
+		m.message = ""
+		if err := m.setTodoDate(); err != nil {
+			m.message = err.Error()
+			return m, nil
 		}
+		m.mode = TodoView
+		m.inputMode = false
+		m.input.Submit()
 	default:
-		m.inputValue += msg.String()
+		m.input.Update(msg)
+	}
+	return m, nil
+}
+
+func (m Model) handleTagFilterKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	tags := m.allTags()
+	// Reloads may have removed tags since the cursor was moved
+	m.tagCursor = min(m.tagCursor, len(tags))
+	switch msg.String() {
+	case "ctrl+c", "q":
+		return m, tea.Quit
+	case "esc":
+		m.mode = m.filterReturn
+	case "up", "k":
+		if m.tagCursor > 0 {
+			m.tagCursor--
+		}
+	case "down", "j":
+		// The first entry clears the filter
+		if m.tagCursor < len(tags) {
+			m.tagCursor++
+		}
+	case "enter":
+		m.mode = m.filterReturn
+		if m.tagCursor == 0 {
+			m.setFilter("")
+		} else {
+			m.setFilter(tags[m.tagCursor-1].name)
+		}
 	}
 	return m, nil
 }
@@ -305,6 +570,10 @@ func (m Model) handleConfirmDeleteProjectKeys(msg tea.KeyMsg) (tea.Model, tea.Cm
 }
 
 func (m Model) View() string {
+	if m.conflict != "" {
+		return m.renderConflictView()
+	}
+
 	switch m.mode {
 	case ProjectView:
 		return m.renderProjectView()
@@ -314,12 +583,30 @@ func (m Model) View() string {
 		return m.renderCreateProjectView()
 	case CreateTodoView:
 		return m.renderCreateTodoView()
+	case CreateSubtaskView:
+		return m.renderCreateSubtaskView()
 	case EditTodoView:
 		return m.renderEditTodoView()
+	case DueDateView, ScheduledDateView:
+		return m.renderDateInputView()
 	case HelpView:
 		return m.renderHelpView()
 	case ConfirmDeleteProjectView:
 		return m.renderConfirmDeleteProjectView()
+	case TagFilterView:
+		return m.renderTagFilterView()
+	case TrashView:
+		return m.renderTrashView()
+	case ArchiveView:
+		return m.renderArchiveView()
+	case TransferTodoView:
+		return m.renderTransferView()
+	case RenameProjectView:
+		return m.renderRenameProjectView()
+	case RenameFileView:
+		return m.renderRenameFileView()
+	case EditNotesView:
+		return m.renderEditNotesView()
 	}
 	return ""
 }
@@ -344,26 +631,48 @@ var (
 
 	mutedStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("#888888"))
+
+	overdueStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#FF4040")).
+			Bold(true)
+
+	dueTodayStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#FFB347"))
+
+	errorStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#FF4040"))
+
+	tagStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#4ECDC4"))
+
+	contextStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#C792EA"))
+
+	priorityStyles = map[models.Priority]lipgloss.Style{
+		models.PriorityHighest: lipgloss.NewStyle().Foreground(lipgloss.Color("#FF4040")).Bold(true),
+		models.PriorityHigh:    lipgloss.NewStyle().Foreground(lipgloss.Color("#FF8C42")).Bold(true),
+		models.PriorityMedium:  lipgloss.NewStyle().Foreground(lipgloss.Color("#FFD166")),
+		models.PriorityLow:     lipgloss.NewStyle().Foreground(lipgloss.Color("#6CA0DC")),
+		models.PriorityLowest:  lipgloss.NewStyle().Foreground(lipgloss.Color("#888888")),
+	}
 )
 
 func (m Model) renderProjectView() string {
-	title := titleStyle.Render("Projects")
+	title := titleStyle.Render("Projects" + m.filterLabel())
 
 	var lines []string
 	for i, project := range m.data.Projects {
+		if !m.projectVisible(i) {
+			continue
+		}
+
 		cursor := " "
 		projectName := project.Name
 		if i == m.projectCursor && !m.inExpandedTodo {
 			cursor = ">"
 			projectName = selectedStyle.Render(project.Name)
 		}
-		todoCount := len(project.Todos)
-		completedCount := 0
-		for _, todo := range project.Todos {
-			if todo.Completed {
-				completedCount++
-			}
-		}
+		completedCount, todoCount := models.CountTodos(project.Todos)
 
 		expandIcon := "▶"
 		if m.expandedProjects[i] {
@@ -375,25 +684,14 @@ func (m Model) renderProjectView() string {
 
 		// Show todos if expanded
 		if m.expandedProjects[i] {
-			// Sort todos before displaying
-			project.SortTodos()
-			for j, todo := range project.Todos {
+			for j, row := range m.projectRows(&project) {
 				todoCursor := " "
-				todoText := todo.Title
-				if i == m.projectCursor && m.inExpandedTodo && j == m.expandedTodoCursor {
+				selected := i == m.projectCursor && m.inExpandedTodo && j == m.expandedTodoCursor
+				if selected {
 					todoCursor = ">"
-					if !todo.Completed {
-						todoText = selectedStyle.Render(todoText)
-					}
-				}
-
-				checkbox := "☐"
-				if todo.Completed {
-					checkbox = "☑"
-					todoText = completedStyle.Render(todoText)
 				}
 
-				todoLine := fmt.Sprintf("  %s %s %s", todoCursor, checkbox, todoText)
+				todoLine := fmt.Sprintf("  %s %s", todoCursor, renderTodoRow(row, selected))
 				lines = append(lines, todoLine)
 			}
 		}
@@ -402,11 +700,13 @@ func (m Model) renderProjectView() string {
 	content := strings.Join(lines, "\n")
 	if len(m.data.Projects) == 0 {
 		content = "No projects yet. Press 'n' to create one!"
+	} else if len(lines) == 0 {
+		content = fmt.Sprintf("No todos tagged %s. Press 'F' to clear the filter.", m.filter)
 	}
 
-	help := mutedStyle.Render("\n\ntab (expand), n (new), d (delete), ? (help), q (quit)")
+	help := mutedStyle.Render("\n\ntab (expand), n (new), r (rename), d (delete), t (trash), f (filter), u (undo), ? (help), q (quit)")
 
-	return title + "\n" + content + help
+	return title + "\n" + content + m.renderMessage() + help
 }
 
 func (m Model) renderTodoView() string {
@@ -415,68 +715,94 @@ func (m Model) renderTodoView() string {
 		return "No project selected"
 	}
 
-	title := titleStyle.Render(currentProject.Name)
-
-	// Sort todos before displaying
-	currentProject.SortTodos()
+	title := titleStyle.Render(currentProject.Name + m.filterLabel() +
+		" · sorted by " + sortLabels[m.projectSortMode(currentProject)])
 
 	var todos []string
-	for i, todo := range currentProject.Todos {
+	for i, row := range m.projectRows(currentProject) {
 		cursor := " "
 		if i == m.todoCursor {
 			cursor = ">"
 		}
 
-		checkbox := "☐"
-		todoText := todo.Title
-		if todo.Completed {
-			checkbox = "☑"
-			todoText = completedStyle.Render(todoText)
-		}
-
-		if i == m.todoCursor && !todo.Completed {
-			todoText = selectedStyle.Render(todoText)
-		}
-
-		line := fmt.Sprintf("%s %s %s", cursor, checkbox, todoText)
+		line := fmt.Sprintf("%s %s", cursor, renderTodoRow(row, i == m.todoCursor))
 		todos = append(todos, line)
 	}
 
 	content := strings.Join(todos, "\n")
-	if len(todos) == 0 {
+	if len(todos) == 0 && m.filter != "" {
+		content = fmt.Sprintf("No todos tagged %s. Press 'F' to clear the filter.", m.filter)
+	} else if len(todos) == 0 {
 		content = "No todos yet. Press 'n' to create one!"
 	}
+	if m.showNotes {
+		content += m.renderNotes()
+	}
 
-	help := mutedStyle.Render("\n\nn (new), d (delete), ? (help), q (quit)")
+	help := mutedStyle.Render("\n\nn (new), a (subtask), tab (fold), d (delete), m (move), i (notes), A (archive), o (sort), f (filter), u (undo), ? (help), q (quit)")
 
-	return title + "\n" + content + help
+	return title + "\n" + content + m.renderMessage() + help
+}
+
+func (m Model) renderMessage() string {
+	if m.message == "" {
+		return ""
+	}
+	return "\n\n" + errorStyle.Render(m.message)
 }
 
 func (m Model) renderCreateProjectView() string {
 	title := titleStyle.Render("Create New Project")
 	prompt := "Project name: "
-	input := inputStyle.Render(m.inputValue + "█")
+	input := m.input.View()
 	help := "\nPress Enter to create, Esc to cancel"
 
-	return title + "\n" + prompt + input + help
+	return title + "\n" + prompt + input + help + m.renderMessage()
 }
 
 func (m Model) renderCreateTodoView() string {
 	title := titleStyle.Render("Create New Todo")
 	prompt := "Todo title: "
-	input := inputStyle.Render(m.inputValue + "█")
+	input := m.input.View()
+	help := "\nPress Enter to create, Esc to cancel"
+
+	return title + "\n" + prompt + input + help + m.renderMessage()
+}
+
+func (m Model) renderCreateSubtaskView() string {
+	title := titleStyle.Render("Create New Subtask")
+	prompt := "Subtask title: "
+	input := m.input.View()
 	help := "\nPress Enter to create, Esc to cancel"
 
+	return title + "\n" + prompt + input + help + m.renderMessage()
+}
+
+func (m Model) renderDateInputView() string {
+	title := titleStyle.Render("Set Due Date")
+	prompt := "Due date: "
+	if m.mode == ScheduledDateView {
+		title = titleStyle.Render("Set Scheduled Date")
+		prompt = "Scheduled date: "
+	}
+	input := m.input.View()
+	help := mutedStyle.Render("\nYYYY-MM-DD, today, tomorrow, +3d, +2w or +1m. Leave empty to clear.")
+	help += "\nPress Enter to save, Esc to cancel"
+
+	if m.message != "" {
+		help += "\n" + errorStyle.Render(m.message)
+	}
+
 	return title + "\n" + prompt + input + help
 }
 
 func (m Model) renderEditTodoView() string {
 	title := titleStyle.Render("Edit Todo")
 	prompt := "Todo title: "
-	input := inputStyle.Render(m.inputValue + "█")
+	input := m.input.View()
 	help := "\nPress Enter to save, Esc to cancel"
 
-	return title + "\n" + prompt + input + help
+	return title + "\n" + prompt + input + help + m.renderMessage()
 }
 
 func (m Model) renderHelpView() string {
@@ -485,20 +811,45 @@ func (m Model) renderHelpView() string {
 	help := `
 Project View:
   ↑/↓, j/k    Navigate projects/tasks
-  Tab         Expand/collapse project
+  Tab         Expand/collapse project or task
   Space       Toggle task (when expanded)
   Enter       Open project or select task
   n           Create new project
+  r           Rename project
+  K/J         Move project or task up/down
   d           Delete project
+  t           Browse the trash
+  f           Filter by tag or context
+  F           Clear filter
+  u           Undo
+  Ctrl+R      Redo
   ?           Show/hide help
   q, Ctrl+C, Esc  Quit
 
 Todo View:
   ↑/↓, j/k    Navigate todos
   Space       Toggle todo completion
+  Tab         Expand/collapse subtasks
   n           Create new todo
+  a           Add subtask to todo
   e           Edit todo
+  i           Show/hide the notes of the todo
+  N           Edit notes
+  E           Edit notes in $EDITOR
+  +/-         Raise/lower priority
+  D           Set due date
+  S           Set scheduled date
+  o           Change sort order
+  K/J         Move todo up/down
+  m           Move todo to another project
+  c           Copy todo to another project
+  f           Filter by tag or context
+  F           Clear filter
   d           Delete todo
+  A           Archive completed todos
+  v           Browse the archive
+  u           Undo
+  Ctrl+R      Redo
   Backspace, Esc  Return to projects
   ?           Show/hide help
   q, Ctrl+C   Quit
@@ -507,7 +858,18 @@ Input Mode:
   Type        Enter text
   Enter       Confirm
   Esc         Cancel
+  ←/→         Move the cursor, by word with Alt or Ctrl
+  Home/End    Go to the start/end
   Backspace   Delete character
+  Ctrl+W      Delete word
+  Ctrl+U/K    Delete to the start/end
+  ↑/↓         Recall previous entries
+
+Notes Editor:
+  Enter       New line
+  ↑/↓/←/→     Move the cursor
+  Ctrl+S      Save
+  Esc         Cancel
 `
 
 	footer := "\nPress any key to return..."
@@ -515,6 +877,31 @@ Input Mode:
 	return title + help + footer
 }
 
+func (m Model) renderTagFilterView() string {
+	title := titleStyle.Render("Filter by Tag")
+
+	entries := []string{"All todos"}
+	for _, tag := range m.allTags() {
+		entries = append(entries, fmt.Sprintf("%s %s", renderTag(tag.name), mutedStyle.Render(fmt.Sprintf("(%d)", tag.count))))
+	}
+
+	var lines []string
+	for i, entry := range entries {
+		cursor := " "
+		if i == m.tagCursor {
+			cursor = ">"
+			if i == 0 {
+				entry = selectedStyle.Render(entry)
+			}
+		}
+		lines = append(lines, fmt.Sprintf("%s %s", cursor, entry))
+	}
+
+	help := mutedStyle.Render("\n\nenter (apply), esc (cancel)")
+
+	return title + "\n" + strings.Join(lines, "\n") + help
+}
+
 func (m Model) renderConfirmDeleteProjectView() string {
 	currentProject := m.getCurrentProject()
 	if currentProject == nil {
@@ -524,8 +911,10 @@ func (m Model) renderConfirmDeleteProjectView() string {
 	title := titleStyle.Render("Delete Project")
 
 	warning := fmt.Sprintf("Are you sure you want to delete the project '%s'?", currentProject.Name)
-	todoCount := len(currentProject.Todos)
-	if todoCount > 0 {
+	_, todoCount := models.CountTodos(currentProject.Todos)
+	if _, ok := m.storage.(storage.Trash); ok {
+		warning += "\nIt will be moved to the trash, press 't' in the project list to restore it."
+	} else if todoCount > 0 {
 		warning += fmt.Sprintf("\nThis will permanently delete %d todo(s).", todoCount)
 	}
 
@@ -541,64 +930,176 @@ func (m *Model) getCurrentProject() *models.Project {
 	return nil
 }
 
+// saveProject writes the project after a change described by label, and
+// records the change for undo once it is saved. A change that could not be
+// saved is recorded along with the next one that is.
+func (m *Model) saveProject(project *models.Project, label string) {
+	m.writeProject(project)
+	if project.Dirty {
+		return
+	}
+	m.record(label, project.Filename, m.projectIndex(project.Filename), project)
+}
+
+// writeProject marks the project as modified and writes it, reporting any
+// error in the status message. Conflicts with changes made by another
+// program prompt the user for the version to keep.
+func (m *Model) writeProject(project *models.Project) {
+	project.Dirty = true
+
+	var err error
+	// Merging with the version on disk replaces the todos of the project
+	m.replaceTodos(m.projectIndex(project.Filename), func() {
+		err = m.storage.SaveProject(project)
+	})
+
+	var conflict *storage.ConflictError
+	if errors.As(err, &conflict) {
+		m.conflict = project.Filename
+	} else if err != nil {
+		m.message = fmt.Sprintf("Could not save %s: %v", project.Name, err)
+	}
+}
+
 func (m *Model) createProject() {
-	project := models.NewProject(strings.TrimSpace(m.inputValue))
+	name := strings.TrimSpace(m.input.Value())
+	project := models.NewProject(name, storage.ProjectFilename(m.storage, m.config, m.data, name, nil))
 	m.data.Projects = append(m.data.Projects, project)
 	m.projectCursor = len(m.data.Projects) - 1
-	m.storage.Save(m.data)
+	m.saveProject(&m.data.Projects[m.projectCursor], "create project")
 }
 
 func (m *Model) deleteProject() {
 	if len(m.data.Projects) > 0 && m.projectCursor < len(m.data.Projects) {
-		m.data.Projects = append(m.data.Projects[:m.projectCursor], m.data.Projects[m.projectCursor+1:]...)
-		if m.projectCursor >= len(m.data.Projects) && len(m.data.Projects) > 0 {
-			m.projectCursor = len(m.data.Projects) - 1
+		project := &m.data.Projects[m.projectCursor]
+		if err := m.storage.DeleteProject(project); err != nil && !os.IsNotExist(err) {
+			m.message = fmt.Sprintf("Could not delete %s: %v", project.Name, err)
+			return
 		}
-		m.storage.Save(m.data)
+
+		m.record("delete project", project.Filename, m.projectCursor, nil)
+		m.removeProject(m.projectCursor)
 	}
 }
 
 func (m *Model) createTodo() {
 	currentProject := m.getCurrentProject()
 	if currentProject != nil {
-		todo := models.NewTodo(strings.TrimSpace(m.inputValue))
+		todo := models.NewTodo(strings.TrimSpace(m.input.Value()))
 		currentProject.Todos = append(currentProject.Todos, todo)
-		m.todoCursor = len(currentProject.Todos) - 1
-		m.storage.Save(m.data)
+		m.syncCompletion(currentProject)
+		m.selectTodo(&currentProject.Todos[len(currentProject.Todos)-1])
+		m.saveProject(currentProject, "create todo")
 	}
 }
 
+func (m *Model) createSubtask() {
+	currentProject := m.getCurrentProject()
+	row, ok := rowAt(m.projectRows(currentProject), m.todoCursor)
+	if !ok {
+		return
+	}
+
+	parent := row.todo
+	parent.Children = append(parent.Children, models.NewTodo(strings.TrimSpace(m.input.Value())))
+	parent.Collapsed = false
+	m.syncCompletion(currentProject)
+	m.selectTodo(&parent.Children[len(parent.Children)-1])
+	m.saveProject(currentProject, "create subtask")
+}
+
 func (m *Model) deleteTodo() {
 	currentProject := m.getCurrentProject()
-	if currentProject != nil && len(currentProject.Todos) > 0 && m.todoCursor < len(currentProject.Todos) {
-		currentProject.Todos = append(currentProject.Todos[:m.todoCursor], currentProject.Todos[m.todoCursor+1:]...)
-		if m.todoCursor >= len(currentProject.Todos) && len(currentProject.Todos) > 0 {
-			m.todoCursor = len(currentProject.Todos) - 1
-		}
-		m.storage.Save(m.data)
+	row, ok := rowAt(m.projectRows(currentProject), m.todoCursor)
+	if !ok {
+		return
 	}
+
+	*row.siblings = append((*row.siblings)[:row.index], (*row.siblings)[row.index+1:]...)
+	m.syncCompletion(currentProject)
+	if rows := m.projectRows(currentProject); m.todoCursor >= len(rows) && len(rows) > 0 {
+		m.todoCursor = len(rows) - 1
+	}
+	m.saveProject(currentProject, "delete todo")
 }
 
 func (m *Model) editTodo() {
 	currentProject := m.getCurrentProject()
-	if currentProject != nil && len(currentProject.Todos) > 0 && m.todoCursor < len(currentProject.Todos) {
-		currentProject.Todos[m.todoCursor].Title = strings.TrimSpace(m.inputValue)
-		m.storage.Save(m.data)
+	if row, ok := rowAt(m.projectRows(currentProject), m.todoCursor); ok {
+		m.followTodo(func() {
+			row.todo.SetTitle(strings.TrimSpace(m.input.Value()))
+			m.saveProject(currentProject, "edit todo")
+		})
 	}
 }
 
 func (m *Model) toggleTodo() {
 	currentProject := m.getCurrentProject()
-	if currentProject != nil && len(currentProject.Todos) > 0 && m.todoCursor < len(currentProject.Todos) {
-		currentProject.Todos[m.todoCursor].Completed = !currentProject.Todos[m.todoCursor].Completed
-		m.storage.Save(m.data)
+	if row, ok := rowAt(m.projectRows(currentProject), m.todoCursor); ok {
+		m.followTodo(func() {
+			m.toggleRow(currentProject, row)
+			m.saveProject(currentProject, "toggle todo")
+		})
 	}
 }
 
 func (m *Model) toggleExpandedTodo() {
 	currentProject := m.getCurrentProject()
-	if currentProject != nil && len(currentProject.Todos) > 0 && m.expandedTodoCursor < len(currentProject.Todos) {
-		currentProject.Todos[m.expandedTodoCursor].Completed = !currentProject.Todos[m.expandedTodoCursor].Completed
-		m.storage.Save(m.data)
+	if row, ok := rowAt(m.projectRows(currentProject), m.expandedTodoCursor); ok {
+		m.followTodo(func() {
+			m.toggleRow(currentProject, row)
+			m.saveProject(currentProject, "toggle todo")
+		})
 	}
-}
\ No newline at end of file
+}
+
+func (m *Model) setTodoDate() error {
+	currentProject := m.getCurrentProject()
+	row, ok := rowAt(m.projectRows(currentProject), m.todoCursor)
+	if !ok {
+		return nil
+	}
+
+	var date time.Time
+	if strings.TrimSpace(m.input.Value()) != "" {
+		parsed, err := models.ParseDate(m.input.Value(), time.Now())
+		if err != nil {
+			return err
+		}
+		date = parsed
+	}
+
+	m.followTodo(func() {
+		if m.mode == ScheduledDateView {
+			row.todo.Scheduled = date
+			m.saveProject(currentProject, "set scheduled date")
+		} else {
+			row.todo.Due = date
+			m.saveProject(currentProject, "set due date")
+		}
+	})
+	return nil
+}
+
+func (m *Model) changePriority(delta int) {
+	currentProject := m.getCurrentProject()
+	row, ok := rowAt(m.projectRows(currentProject), m.todoCursor)
+	if !ok {
+		return
+	}
+
+	m.followTodo(func() {
+		if delta > 0 {
+			row.todo.RaisePriority()
+		} else {
+			row.todo.LowerPriority()
+		}
+		m.saveProject(currentProject, "change priority")
+	})
+}
+
+func (m *Model) toggleCollapsed(cursor int) {
+	if row, ok := rowAt(m.projectRows(m.getCurrentProject()), cursor); ok && len(row.todo.Children) > 0 {
+		row.todo.Collapsed = !row.todo.Collapsed
+	}
+}
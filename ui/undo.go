@@ -0,0 +1,227 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+
+	"donut/models"
+)
+
+// maxUndo is the number of changes that can be undone.
+const maxUndo = 100
+
+// projectChange holds a project before and after a change, nil when it
+// did not exist.
+type projectChange struct {
+	filename      string
+	index         int
+	before, after *models.Project
+	// renamed is the filename the project had before the change, when
+	// the change renamed its file
+	renamed string
+}
+
+// undoEntry is a change made by a single command.
+type undoEntry struct {
+	label   string
+	changes []projectChange
+}
+
+// record pushes a change of the project at index onto the undo stack.
+// project is nil when the change deleted it.
+func (m *Model) record(label string, filename string, index int, project *models.Project) {
+	m.push(label, m.change(filename, index, project))
+}
+
+// change returns the change of the project at index since it was last
+// saved, and remembers project as saved.
+func (m *Model) change(filename string, index int, project *models.Project) projectChange {
+	change := projectChange{filename: filename, index: index, before: m.saved[filename]}
+	if project != nil {
+		after := project.Clone()
+		change.after = &after
+	}
+	m.setSaved(filename, project)
+	return change
+}
+
+// push adds a command changing one or more projects to the undo stack.
+// Undoing it restores the projects in the reverse order of changes.
+func (m *Model) push(label string, changes ...projectChange) {
+	m.undoStack = append(m.undoStack, undoEntry{label: label, changes: changes})
+	if len(m.undoStack) > maxUndo {
+		m.undoStack = m.undoStack[1:]
+	}
+	m.redoStack = nil
+}
+
+// setSaved remembers the project as last saved, the version a change
+// recorded next is undone to.
+func (m *Model) setSaved(filename string, project *models.Project) {
+	if project == nil {
+		delete(m.saved, filename)
+		return
+	}
+	saved := project.Clone()
+	m.saved[filename] = &saved
+}
+
+// forgetHistory drops the undo and redo entries changing the project, as
+// restoring their versions after it was reloaded would silently overwrite
+// the changes made by another program.
+func (m *Model) forgetHistory(filename string) {
+	m.undoStack = withoutProject(m.undoStack, filename)
+	m.redoStack = withoutProject(m.redoStack, filename)
+}
+
+func withoutProject(entries []undoEntry, filename string) []undoEntry {
+	return slices.DeleteFunc(entries, func(entry undoEntry) bool {
+		return slices.ContainsFunc(entry.changes, func(change projectChange) bool {
+			return change.filename == filename || change.renamed == filename
+		})
+	})
+}
+
+func (m *Model) undo() {
+	if len(m.undoStack) == 0 {
+		m.message = "Nothing to undo"
+		return
+	}
+	entry := m.undoStack[len(m.undoStack)-1]
+	m.undoStack = m.undoStack[:len(m.undoStack)-1]
+
+	for i := len(entry.changes) - 1; i >= 0; i-- {
+		change := entry.changes[i]
+		filename := change.filename
+		if change.renamed != "" {
+			if err := m.moveFile(change.filename, change.renamed); err != nil {
+				m.undoStack = append(m.undoStack, entry)
+				m.message = fmt.Sprintf("Could not rename %s back: %v", change.filename, err)
+				return
+			}
+			filename = change.renamed
+		}
+		m.restore(filename, change.index, change.before)
+	}
+	m.redoStack = append(m.redoStack, entry)
+	if m.message == "" {
+		m.message = "Undid " + entry.label
+	}
+}
+
+func (m *Model) redo() {
+	if len(m.redoStack) == 0 {
+		m.message = "Nothing to redo"
+		return
+	}
+	entry := m.redoStack[len(m.redoStack)-1]
+	m.redoStack = m.redoStack[:len(m.redoStack)-1]
+
+	for _, change := range entry.changes {
+		if change.renamed != "" {
+			if err := m.moveFile(change.renamed, change.filename); err != nil {
+				m.redoStack = append(m.redoStack, entry)
+				m.message = fmt.Sprintf("Could not rename %s again: %v", change.renamed, err)
+				return
+			}
+		}
+		m.restore(change.filename, change.index, change.after)
+	}
+	m.undoStack = append(m.undoStack, entry)
+	if m.message == "" {
+		m.message = "Redid " + entry.label
+	}
+}
+
+// restore brings back a version of a project, deleting it when version is
+// nil, and saves it.
+func (m *Model) restore(filename string, index int, version *models.Project) {
+	i := m.projectIndex(filename)
+	if version == nil {
+		if i >= 0 {
+			project := &m.data.Projects[i]
+			if err := m.storage.DeleteProject(project); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				m.message = fmt.Sprintf("Could not delete %s: %v", project.Name, err)
+				return
+			}
+			m.removeProject(i)
+		}
+		m.setSaved(filename, nil)
+		return
+	}
+
+	project := version.Clone()
+	if i < 0 {
+		i = min(index, len(m.data.Projects))
+		m.insertProject(i, project)
+	} else {
+		// The lines of the file moved since the version was saved
+		matchLineNums(project.Todos, m.data.Projects[i].Todos)
+		matchLineNums(project.Archive, m.data.Projects[i].Archive)
+		m.replaceTodos(i, func() {
+			m.data.Projects[i] = project
+		})
+	}
+	m.writeProject(&m.data.Projects[i])
+	m.setSaved(filename, &m.data.Projects[i])
+}
+
+// insertProject puts a project back into the list at index, keeping the
+// cursor on the same project.
+func (m *Model) insertProject(index int, project models.Project) {
+	m.data.Projects = append(m.data.Projects, models.Project{})
+	copy(m.data.Projects[index+1:], m.data.Projects[index:])
+	m.data.Projects[index] = project
+
+	expanded := make(map[int]bool)
+	for i, open := range m.expandedProjects {
+		if i >= index {
+			i++
+		}
+		expanded[i] = open
+	}
+	m.expandedProjects = expanded
+
+	if m.mode == ProjectView {
+		m.projectCursor = index
+		m.inExpandedTodo = false
+		m.expandedTodoCursor = 0
+	} else if index <= m.projectCursor && len(m.data.Projects) > 1 {
+		m.projectCursor++
+	}
+}
+
+// matchLineNums gives the todos of a restored version the line numbers of
+// the current todos, so that the file is rewritten in place. Todos are
+// matched by ID, or by title and creation date for todos without one, or
+// else by their line when the current todo there has no match. The other
+// todos are written as new lines.
+func matchLineNums(version, current []models.Todo) {
+	byLine := make(map[int]*models.Todo)
+	collectLines(current, byLine)
+
+	var match func(todos []models.Todo)
+	match = func(todos []models.Todo) {
+		for i := range todos {
+			todo := &todos[i]
+			if same := findSameTodo(current, todo); same != nil {
+				todo.LineNum = same.LineNum
+			} else if other, ok := byLine[todo.LineNum]; !ok || findSameTodo(version, other) != nil {
+				todo.LineNum = -1
+			}
+			match(todo.Children)
+		}
+	}
+	match(version)
+}
+
+func collectLines(todos []models.Todo, byLine map[int]*models.Todo) {
+	for i := range todos {
+		if todos[i].LineNum > 0 {
+			byLine[todos[i].LineNum] = &todos[i]
+		}
+		collectLines(todos[i].Children, byLine)
+	}
+}